@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"template/internal/phonebook"
+	"time"
+)
+
+// AuditExport writes the audit log as JSON lines for ingestion by a SIEM.
+// Without -org it exports every organization, including events such as
+// failed logins that belong to none.
+func AuditExport(args []string) {
+	fs := flag.NewFlagSet("audit-export", flag.ExitOnError)
+	organizationID := fs.Int("org", 0, "only export events of this organization")
+	action := fs.String("action", "", "only export events with this action")
+	since := fs.String("since", "", "only export events at or after this RFC 3339 time")
+	until := fs.String("until", "", "only export events before this RFC 3339 time")
+	fs.Parse(args)
+
+	filter := &phonebook.AuditFilter{
+		OrganizationID: *organizationID,
+		Action:         phonebook.AuditAction(*action),
+	}
+
+	var err error
+	if *since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, *since)
+		if err != nil {
+			log.Fatalf("invalid -since: %s", err)
+		}
+	}
+
+	if *until != "" {
+		filter.Until, err = time.Parse(time.RFC3339, *until)
+		if err != nil {
+			log.Fatalf("invalid -until: %s", err)
+		}
+	}
+
+	repo, closeRepo, err := connect(context.Background())
+	if err != nil {
+		log.Fatalf("error connect DB: %s", err)
+	}
+	defer closeRepo()
+
+	auditSvc := phonebook.NewAuditService(repo)
+
+	out := bufio.NewWriter(os.Stdout)
+	err = auditSvc.ExportJSONL(context.Background(), filter, out)
+	if err != nil {
+		log.Fatalf("error export audit log: %s", err)
+	}
+
+	if err := out.Flush(); err != nil {
+		log.Fatalf("error write audit log: %s", err)
+	}
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"expvar"
+	"template/internal/cache"
+	"template/internal/config"
+	"template/internal/repository"
+	"time"
+)
+
+// withCache reads addresses of repo through an in-process cache when
+// CACHE_ENABLED is set, publishing its hit and miss counts on the
+// /debug/vars of DEBUG_ADDR.
+func withCache(repo repository.Repository) repository.Repository {
+	if config.CACHE_ENABLED != "true" {
+		return repo
+	}
+
+	cached := repository.NewCachedRepository(
+		repo,
+		cache.NewLRU(intOr(config.CACHE_SIZE, 10000)),
+		durationOr(config.CACHE_TTL, time.Minute),
+	)
+
+	expvar.Publish("address_cache", expvar.Func(func() any {
+		return cached.Stats()
+	}))
+
+	return cached
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"template/internal/config"
+	"template/internal/db"
+	"template/internal/repository"
+	"time"
+)
+
+// connect opens the repository selected by DATABASE_DRIVER and returns it
+// with a function that closes its connections.
+func connect(ctx context.Context) (repository.Repository, func() error, error) {
+	switch config.DATABASE_DRIVER {
+	case "", "pgx":
+		return connectPgx()
+	case "pgxpool":
+		return connectPgxPool(ctx)
+	case "sqlite":
+		return connectSQLite()
+	default:
+		return nil, nil, fmt.Errorf("unknown DATABASE_DRIVER %q", config.DATABASE_DRIVER)
+	}
+}
+
+func connectPgx() (repository.Repository, func() error, error) {
+	db, err := db.ConnectDB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repo := repository.NewPostgreSQLRepository(db)
+
+	closeRepo, err := withReplicas(repo, db.Close)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return repo, closeRepo, nil
+}
+
+func connectPgxPool(ctx context.Context) (repository.Repository, func() error, error) {
+	pool, err := db.ConnectPool(ctx, db.PoolConfig{
+		MaxConns:               intOr(config.DATABASE_MAX_CONNS, 0),
+		MinConns:               intOr(config.DATABASE_MIN_CONNS, 0),
+		MaxConnLifetime:        durationOr(config.DATABASE_MAX_CONN_LIFETIME, 0),
+		MaxConnIdleTime:        durationOr(config.DATABASE_MAX_CONN_IDLE_TIME, 0),
+		StatementCacheCapacity: intOr(config.DATABASE_STATEMENT_CACHE_CAPACITY, 0),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	closePool := func() error {
+		pool.Close()
+		return nil
+	}
+
+	repo := repository.NewPgxPoolRepository(pool)
+
+	closeRepo, err := withReplicas(repo.PostgreSQLRepository, closePool)
+	if err != nil {
+		pool.Close()
+		return nil, nil, err
+	}
+
+	return repo, closeRepo, nil
+}
+
+func connectSQLite() (repository.Repository, func() error, error) {
+	db, err := db.ConnectSQLite(config.DATABASE_PATH)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return repository.NewSQLiteRepository(db), db.Close, nil
+}
+
+// withReplicas routes the reads of repo to DATABASE_REPLICAS, when set, and
+// extends closeRepo to stop their health checks and close them.
+func withReplicas(repo *repository.PostgreSQLRepository, closeRepo func() error) (func() error, error) {
+	if config.DATABASE_REPLICAS == "" {
+		return closeRepo, nil
+	}
+
+	dbs, err := db.ConnectReplicas(config.DATABASE_REPLICAS)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := repository.NewReplicas(dbs...)
+	repo.UseReplicas(replicas)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go replicas.Watch(ctx, durationOr(config.DATABASE_REPLICA_CHECK_INTERVAL, 5*time.Second))
+
+	return func() error {
+		cancel()
+
+		err := replicas.Close()
+		if err != nil {
+			closeRepo()
+			return err
+		}
+
+		return closeRepo()
+	}, nil
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"template/internal/common"
+	"template/internal/config"
+	"template/internal/phonebook"
+)
+
+type mappingFlag phonebook.ColumnMapping
+
+func (m mappingFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for field, col := range m {
+		pairs = append(pairs, field+"="+col)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (m mappingFlag) Set(value string) error {
+	field, col, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("mapping must be field=column, got %q", value)
+	}
+
+	m[field] = col
+	return nil
+}
+
+func Import(args []string) {
+	mapping := mappingFlag{}
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	userID := fs.Int("user", 0, "ID of the user owning the imported addresses")
+	organizationID := fs.Int("org", 0, "ID of the organization the addresses are imported into")
+	file := fs.String("file", "", "path to the CSV file, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "validate rows without writing them")
+	fs.Var(mapping, "map", "column mapping as field=column, repeatable (fields: name, phone_number)")
+	fs.Parse(args)
+
+	if *userID <= 0 || *organizationID <= 0 || *file == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	in := os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("error open file: %s", err)
+		}
+		defer f.Close()
+
+		in = f
+	}
+
+	repo, closeRepo, err := connect(context.Background())
+	if err != nil {
+		log.Fatalf("error connect DB: %s", err)
+	}
+	defer closeRepo()
+
+	addressSvc := phonebook.NewAddressService(repo, intOr(config.BATCH_MAX_SIZE, phonebook.DefaultMaxBatchSize))
+
+	ctx := common.WithActorID(common.WithTenantID(context.Background(), *organizationID), *userID)
+
+	result, err := addressSvc.ImportCSV(ctx, *userID, in, phonebook.ColumnMapping(mapping), *dryRun)
+	if err != nil {
+		log.Fatalf("error import: %s", err)
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	if err := out.Encode(result); err != nil {
+		log.Fatalf("error write result: %s", err)
+	}
+
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+}
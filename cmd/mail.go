@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"template/internal/common"
+	"template/internal/config"
+	"template/internal/mail"
+	"template/internal/phonebook"
+)
+
+// mailer sends through SMTP_ADDR. Without a relay, email is written to the
+// log only when SMTP_LOG=true, since it carries confirmation codes; otherwise
+// there is no mailer and email changes are refused.
+func mailer() phonebook.Mailer {
+	if config.SMTP_ADDR != "" {
+		return mail.NewSMTP(config.SMTP_ADDR, config.SMTP_FROM, config.SMTP_USERNAME, config.SMTP_PASSWORD)
+	}
+
+	if config.SMTP_LOG == "true" {
+		common.Log.Warn("SMTP_LOG=true: outgoing email, confirmation codes included, is written to the log")
+		return mail.Log{}
+	}
+
+	return nil
+}
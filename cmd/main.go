@@ -2,18 +2,19 @@ package cmd
 
 import (
 	"context"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"template/internal/api"
 	"template/internal/common"
 	"template/internal/config"
-	"template/internal/db"
 	"template/internal/handler"
 	"template/internal/phonebook"
-	"template/internal/repository"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,47 +25,170 @@ import (
 func Execute() {
 	common.SetLogger(common.NewLogrusLogger())
 
-	db, err := db.ConnectDB()
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		Import(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "audit-export" {
+		AuditExport(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "oidc-mock" {
+		OIDCMock(os.Args[2:])
+		return
+	}
+
+	Serve()
+}
+
+func Serve() {
+	repo, closeRepo, err := connect(context.Background())
 	if err != nil {
 		log.Fatalf("error connect DB: %s", err)
 	}
 	defer func() {
-		if err := db.Close(); err != nil {
+		if err := closeRepo(); err != nil {
 			log.Fatal()
 		}
 	}()
 
-	repo := repository.NewPostgreSQLRepository(db)
+	repo = withCache(repo)
 
 	userSvc := phonebook.NewUserService(repo)
-	addressSvc := phonebook.NewAddressService(repo)
-
+	addressSvc := phonebook.NewAddressService(repo, intOr(config.BATCH_MAX_SIZE, phonebook.DefaultMaxBatchSize))
+	duplicateSvc := phonebook.NewDuplicateService(repo)
+	tagSvc := phonebook.NewTagService(repo)
+	collectionSvc := phonebook.NewCollectionService(repo)
+	organizationSvc := phonebook.NewOrganizationService(repo)
+	auditSvc := phonebook.NewAuditService(repo)
+	sessionSvc := phonebook.NewSessionService(repo)
+	accountSvc := phonebook.NewAccountService(repo, mailer(), durationOr(config.EMAIL_CHANGE_TTL, phonebook.DefaultEmailChangeTTL))
+
+	duplicateHandler := handler.NewDuplicateHandler(duplicateSvc)
+	tagHandler := handler.NewTagHandler(tagSvc)
+	collectionHandler := handler.NewCollectionHandler(collectionSvc)
+	organizationHandler := handler.NewOrganizationHandler(organizationSvc)
+	auditHandler := handler.NewAuditHandler(auditSvc)
+	sessionHandler := handler.NewSessionHandler(sessionSvc)
+	accountHandler := handler.NewAccountHandler(accountSvc)
 	handler := handler.NewRESTHandler(userSvc, addressSvc)
 
-	r := api.Setup(
+	auth := api.Authentication(sessionSvc)
+	idempotent := api.Idempotency(repo, durationOr(config.IDEMPOTENCY_TTL, 24*time.Hour))
+
+	routes := []api.Route{
 		api.Route{Method: "POST", Path: "/register", Handler: []gin.HandlerFunc{handler.Register}},
 		api.Route{Method: "POST", Path: "/login", Handler: []gin.HandlerFunc{handler.Login}},
 
-		api.Route{Method: "POST", Path: "/addresses", Handler: []gin.HandlerFunc{api.Authentication(), handler.NewAddress}},
-		api.Route{Method: "GET", Path: "/addresses", Handler: []gin.HandlerFunc{api.Authentication(), handler.Addresses}},
-		api.Route{Method: "GET", Path: "/addresses/user", Handler: []gin.HandlerFunc{api.Authentication(), handler.GetAddressesByUserID}},
-		api.Route{Method: "GET", Path: "/addresses/:id", Handler: []gin.HandlerFunc{api.Authentication(), handler.GetAddressByID}},
-		api.Route{Method: "PUT", Path: "/addresses/:id", Handler: []gin.HandlerFunc{api.Authentication(), handler.UpdateAddress}},
-		api.Route{Method: "DELETE", Path: "/addresses/:id", Handler: []gin.HandlerFunc{api.Authentication(), handler.DeleteAddress}},
-	)
+		api.Route{Method: "POST", Path: "/addresses", Handler: []gin.HandlerFunc{auth, idempotent, handler.NewAddress}},
+		api.Route{Method: "GET", Path: "/addresses", Handler: []gin.HandlerFunc{auth, handler.Addresses}},
+		api.Route{Method: "GET", Path: "/addresses/user", Handler: []gin.HandlerFunc{auth, handler.GetAddressesByUserID}},
+		api.Route{Method: "GET", Path: "/addresses/user/export", Handler: []gin.HandlerFunc{auth, handler.ExportAddresses}},
+		api.Route{Method: "GET", Path: "/addresses/trash", Handler: []gin.HandlerFunc{auth, handler.Trash}},
+		api.Route{Method: "GET", Path: "/addresses/search", Handler: []gin.HandlerFunc{auth, handler.SearchAddresses}},
+		api.Route{Method: "POST", Path: "/addresses/import", Handler: []gin.HandlerFunc{auth, idempotent, handler.ImportAddresses}},
+		api.Route{Method: "POST", Path: "/addresses/batch", Handler: []gin.HandlerFunc{auth, idempotent, handler.Batch}},
+		api.Route{Method: "GET", Path: "/addresses/duplicates", Handler: []gin.HandlerFunc{auth, duplicateHandler.Duplicates}},
+		api.Route{Method: "POST", Path: "/addresses/merge", Handler: []gin.HandlerFunc{auth, idempotent, duplicateHandler.Merge}},
+		api.Route{Method: "POST", Path: "/addresses/merges/:id/undo", Handler: []gin.HandlerFunc{auth, duplicateHandler.UndoMerge}},
+		api.Route{Method: "GET", Path: "/addresses/:id", Handler: []gin.HandlerFunc{auth, handler.GetAddressByID}},
+		api.Route{Method: "PUT", Path: "/addresses/:id", Handler: []gin.HandlerFunc{auth, handler.UpdateAddress}},
+		api.Route{Method: "PATCH", Path: "/addresses/:id", Handler: []gin.HandlerFunc{auth, handler.PatchAddress}},
+		api.Route{Method: "DELETE", Path: "/addresses/:id", Handler: []gin.HandlerFunc{auth, handler.DeleteAddress}},
+		api.Route{Method: "POST", Path: "/addresses/:id/restore", Handler: []gin.HandlerFunc{auth, handler.RestoreAddress}},
+		api.Route{Method: "GET", Path: "/addresses/:id/history", Handler: []gin.HandlerFunc{auth, handler.History}},
+		api.Route{Method: "POST", Path: "/addresses/:id/revert/:version", Handler: []gin.HandlerFunc{auth, handler.RevertAddress}},
+		api.Route{Method: "PUT", Path: "/addresses/:id/tags/:tag_id", Handler: []gin.HandlerFunc{auth, tagHandler.TagAddress}},
+		api.Route{Method: "DELETE", Path: "/addresses/:id/tags/:tag_id", Handler: []gin.HandlerFunc{auth, tagHandler.UntagAddress}},
+
+		api.Route{Method: "POST", Path: "/tags", Handler: []gin.HandlerFunc{auth, idempotent, tagHandler.NewTag}},
+		api.Route{Method: "GET", Path: "/tags", Handler: []gin.HandlerFunc{auth, tagHandler.Tags}},
+		api.Route{Method: "GET", Path: "/tags/:id", Handler: []gin.HandlerFunc{auth, tagHandler.GetTagByID}},
+		api.Route{Method: "PUT", Path: "/tags/:id", Handler: []gin.HandlerFunc{auth, tagHandler.UpdateTag}},
+		api.Route{Method: "DELETE", Path: "/tags/:id", Handler: []gin.HandlerFunc{auth, tagHandler.DeleteTag}},
+		api.Route{Method: "POST", Path: "/tags/bulk/tag", Handler: []gin.HandlerFunc{auth, tagHandler.BulkTag}},
+		api.Route{Method: "POST", Path: "/tags/bulk/untag", Handler: []gin.HandlerFunc{auth, tagHandler.BulkUntag}},
+
+		api.Route{Method: "POST", Path: "/collections", Handler: []gin.HandlerFunc{auth, idempotent, collectionHandler.NewCollection}},
+		api.Route{Method: "GET", Path: "/collections", Handler: []gin.HandlerFunc{auth, collectionHandler.Collections}},
+		api.Route{Method: "GET", Path: "/collections/:id", Handler: []gin.HandlerFunc{auth, collectionHandler.GetCollectionByID}},
+		api.Route{Method: "DELETE", Path: "/collections/:id", Handler: []gin.HandlerFunc{auth, collectionHandler.DeleteCollection}},
+		api.Route{Method: "GET", Path: "/collections/:id/addresses", Handler: []gin.HandlerFunc{auth, collectionHandler.Addresses}},
+		api.Route{Method: "POST", Path: "/collections/:id/addresses", Handler: []gin.HandlerFunc{auth, idempotent, collectionHandler.NewAddress}},
+		api.Route{Method: "PUT", Path: "/collections/:id/addresses/:address_id", Handler: []gin.HandlerFunc{auth, collectionHandler.AddAddress}},
+		api.Route{Method: "DELETE", Path: "/collections/:id/addresses/:address_id", Handler: []gin.HandlerFunc{auth, collectionHandler.RemoveAddress}},
+		api.Route{Method: "GET", Path: "/collections/:id/shares", Handler: []gin.HandlerFunc{auth, collectionHandler.Shares}},
+		api.Route{Method: "POST", Path: "/collections/:id/shares", Handler: []gin.HandlerFunc{auth, idempotent, collectionHandler.Invite}},
+		api.Route{Method: "DELETE", Path: "/shares/:id", Handler: []gin.HandlerFunc{auth, collectionHandler.RevokeShare}},
+		api.Route{Method: "GET", Path: "/invitations", Handler: []gin.HandlerFunc{auth, collectionHandler.Invitations}},
+		api.Route{Method: "POST", Path: "/invitations/:id/accept", Handler: []gin.HandlerFunc{auth, collectionHandler.AcceptInvitation}},
+
+		api.Route{Method: "POST", Path: "/organizations", Handler: []gin.HandlerFunc{auth, idempotent, organizationHandler.NewOrganization}},
+		api.Route{Method: "GET", Path: "/organizations", Handler: []gin.HandlerFunc{auth, organizationHandler.Organizations}},
+		api.Route{Method: "POST", Path: "/organizations/:id/token", Handler: []gin.HandlerFunc{auth, organizationHandler.SwitchOrganization}},
+		api.Route{Method: "GET", Path: "/organizations/:id/members", Handler: []gin.HandlerFunc{auth, organizationHandler.Members}},
+		api.Route{Method: "POST", Path: "/organizations/:id/members", Handler: []gin.HandlerFunc{auth, idempotent, organizationHandler.InviteMember}},
+		api.Route{Method: "DELETE", Path: "/organizations/:id/members/:user_id", Handler: []gin.HandlerFunc{auth, organizationHandler.RemoveMember}},
+
+		api.Route{Method: "GET", Path: "/me", Handler: []gin.HandlerFunc{auth, accountHandler.Profile}},
+		api.Route{Method: "PATCH", Path: "/me", Handler: []gin.HandlerFunc{auth, accountHandler.UpdateProfile}},
+		api.Route{Method: "DELETE", Path: "/me", Handler: []gin.HandlerFunc{auth, accountHandler.DeleteAccount}},
+		api.Route{Method: "POST", Path: "/me/export", Handler: []gin.HandlerFunc{auth, accountHandler.Export}},
+		api.Route{Method: "PUT", Path: "/me/password", Handler: []gin.HandlerFunc{auth, accountHandler.ChangePassword}},
+		api.Route{Method: "POST", Path: "/me/email", Handler: []gin.HandlerFunc{auth, accountHandler.RequestEmailChange}},
+		api.Route{Method: "POST", Path: "/me/email/confirm", Handler: []gin.HandlerFunc{auth, accountHandler.ConfirmEmailChange}},
+		api.Route{Method: "GET", Path: "/me/sessions", Handler: []gin.HandlerFunc{auth, sessionHandler.Sessions}},
+		api.Route{Method: "DELETE", Path: "/me/sessions", Handler: []gin.HandlerFunc{auth, sessionHandler.RevokeOtherSessions}},
+		api.Route{Method: "DELETE", Path: "/me/sessions/:id", Handler: []gin.HandlerFunc{auth, sessionHandler.RevokeSession}},
+
+		api.Route{Method: "GET", Path: "/audit", Handler: []gin.HandlerFunc{auth, auditHandler.Events}},
+		api.Route{Method: "GET", Path: "/audit/export", Handler: []gin.HandlerFunc{auth, auditHandler.Export}},
+	}
+
+	if config.OIDC_ISSUER != "" {
+		routes = append(routes, oidcRoutes(userSvc)...)
+	}
+
+	r := api.Setup(routes...)
+
+	err = r.SetTrustedProxies(strings.Fields(config.TRUSTED_PROXIES))
+	if err != nil {
+		log.Fatalf("error TRUSTED_PROXIES: %s", err)
+	}
 
 	srv := http.Server{
 		Addr:    config.PORT,
 		Handler: r,
 	}
 
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	go PurgeTrash(purgeCtx, addressSvc)
+	go PurgeIdempotencyKeys(purgeCtx, repo)
+	go PurgeSessions(purgeCtx, sessionSvc)
+
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("server error: %v", err)
 		}
 	}()
 
-	quit := make(chan os.Signal)
+	// /debug/vars is served on its own listener, meant to be reachable
+	// from inside the deployment only.
+	if config.DEBUG_ADDR != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/debug/vars", expvar.Handler())
+
+			if err := http.ListenAndServe(config.DEBUG_ADDR, mux); err != nil {
+				log.Fatalf("debug server error: %v", err)
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
@@ -81,3 +205,12 @@ func Execute() {
 
 	log.Println("Server exited gracefully")
 }
+
+func intOr(value string, fallback int) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return fallback
+	}
+
+	return n
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"template/internal/api"
+	"template/internal/config"
+	"template/internal/handler"
+	"template/internal/oidc"
+	"template/internal/oidc/oidctest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcRoutes signs users in through the OIDC_ISSUER identity provider.
+func oidcRoutes(userSvc handler.IdentityService) []api.Route {
+	provider := oidc.New(oidc.Config{
+		Issuer:       config.OIDC_ISSUER,
+		ClientID:     config.OIDC_CLIENT_ID,
+		ClientSecret: config.OIDC_CLIENT_SECRET,
+		RedirectURL:  config.OIDC_REDIRECT_URL,
+		Scopes:       strings.Fields(config.OIDC_SCOPES),
+		StateKey:     []byte(config.JWT_SECRET),
+	})
+
+	oidcHandler := handler.NewOIDCHandler(provider, userSvc)
+
+	return []api.Route{
+		{Method: "GET", Path: "/login/oidc", Handler: []gin.HandlerFunc{oidcHandler.Login}},
+		{Method: "GET", Path: "/login/oidc/callback", Handler: []gin.HandlerFunc{oidcHandler.Callback}},
+	}
+}
+
+// OIDCMock serves a local identity provider that signs in one fixed user
+// without asking, for trying out OIDC login. Point OIDC_ISSUER at -issuer.
+func OIDCMock(args []string) {
+	fs := flag.NewFlagSet("oidc-mock", flag.ExitOnError)
+	addr := fs.String("addr", ":9000", "listen address")
+	issuer := fs.String("issuer", "http://localhost:9000", "issuer URL the provider is reached at")
+	clientID := fs.String("client-id", "phonebook", "client ID to accept")
+	clientSecret := fs.String("client-secret", "", "client secret to require, none for a public client")
+	subject := fs.String("subject", "1", "subject of the signed in user")
+	email := fs.String("email", "user@example.com", "email of the signed in user")
+	verified := fs.Bool("email-verified", true, "whether the email is reported as verified")
+	name := fs.String("name", "Test User", "name of the signed in user")
+	fs.Parse(args)
+
+	provider, err := oidctest.New(strings.TrimSuffix(*issuer, "/"), *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("error create provider: %s", err)
+	}
+
+	provider.SetUser(oidctest.User{Subject: *subject, Email: *email, EmailVerified: *verified, Name: *name})
+
+	log.Printf("mock OIDC provider %s listening on %s", provider.Issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, provider))
+}
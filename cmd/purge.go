@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"template/internal/common"
+	"template/internal/config"
+	"template/internal/phonebook"
+	"template/internal/repository"
+	"time"
+)
+
+func durationOr(value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fallback
+	}
+
+	return d
+}
+
+// purgeEvery runs purge right away and then once every interval until ctx
+// is done, so a restart never postpones a purge by a full interval.
+func purgeEvery(ctx context.Context, interval time.Duration, purge func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purge()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func PurgeTrash(ctx context.Context, addressSvc *phonebook.AddressService) {
+	retention := durationOr(config.TRASH_RETENTION, 30*24*time.Hour)
+	interval := durationOr(config.TRASH_PURGE_INTERVAL, time.Hour)
+
+	purgeEvery(ctx, interval, func() {
+		n, err := addressSvc.PurgeTrash(ctx, retention)
+		if err != nil {
+			common.Log.Errorf("purge trash: %s", err)
+		} else if n > 0 {
+			common.Log.Infof("purged %d trashed addresses", n)
+		}
+	})
+}
+
+func PurgeIdempotencyKeys(ctx context.Context, repo repository.Repository) {
+	interval := durationOr(config.IDEMPOTENCY_PURGE_INTERVAL, time.Hour)
+
+	purgeEvery(ctx, interval, func() {
+		_, err := repo.PurgeIdempotencyKeys(ctx, time.Now())
+		if err != nil {
+			common.Log.Errorf("purge idempotency keys: %s", err)
+		}
+	})
+}
+
+// PurgeSessions removes sessions that have been expired or revoked for
+// longer than SESSION_RETENTION.
+func PurgeSessions(ctx context.Context, sessionSvc *phonebook.SessionService) {
+	retention := durationOr(config.SESSION_RETENTION, 30*24*time.Hour)
+	interval := durationOr(config.SESSION_PURGE_INTERVAL, time.Hour)
+
+	purgeEvery(ctx, interval, func() {
+		_, err := sessionSvc.PurgeSessions(ctx, retention)
+		if err != nil {
+			common.Log.Errorf("purge sessions: %s", err)
+		}
+	})
+}
@@ -6,8 +6,164 @@ CREATE TABLE Users (
 
 CREATE TABLE Addresses (
     id BIGSERIAL PRIMARY KEY,
-    user_id BIGINT REFERENCES Users (id) NOT NULL,
+    user_id BIGINT REFERENCES Users (id) ON DELETE CASCADE NOT NULL,
     name VARCHAR NOT NULL,
 	phone_number VARCHAR NOT NULL
 );
 
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX addresses_name_fts_idx ON Addresses USING GIN (to_tsvector('simple', name));
+CREATE INDEX addresses_name_trgm_idx ON Addresses USING GIN (name gin_trgm_ops);
+CREATE INDEX addresses_phone_digits_trgm_idx ON Addresses USING GIN (regexp_replace(phone_number, '\D', '', 'g') gin_trgm_ops);
+
+CREATE TABLE Address_merges (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES Users (id) ON DELETE CASCADE NOT NULL,
+    survivor_id BIGINT NOT NULL,
+    survivor_version INT NOT NULL,
+    previous JSONB NOT NULL,
+    merged JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    undone_at TIMESTAMPTZ
+);
+
+CREATE TABLE Tags (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES Users (id) ON DELETE CASCADE NOT NULL,
+    name VARCHAR NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE Address_tags (
+    address_id BIGINT REFERENCES Addresses (id) ON DELETE CASCADE NOT NULL,
+    tag_id BIGINT REFERENCES Tags (id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (address_id, tag_id)
+);
+
+CREATE TABLE Collections (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES Users (id) ON DELETE CASCADE NOT NULL,
+    name VARCHAR NOT NULL
+);
+
+ALTER TABLE Addresses ADD COLUMN collection_id BIGINT REFERENCES Collections (id) ON DELETE SET NULL;
+
+CREATE TABLE Collection_shares (
+    id BIGSERIAL PRIMARY KEY,
+    collection_id BIGINT REFERENCES Collections (id) ON DELETE CASCADE NOT NULL,
+    user_id BIGINT REFERENCES Users (id) ON DELETE CASCADE NOT NULL,
+    permission VARCHAR NOT NULL CHECK (permission IN ('viewer', 'editor')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    accepted_at TIMESTAMPTZ,
+    UNIQUE (collection_id, user_id)
+);
+
+CREATE TABLE Organizations (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE Memberships (
+    organization_id BIGINT REFERENCES Organizations (id) ON DELETE CASCADE NOT NULL,
+    user_id BIGINT REFERENCES Users (id) ON DELETE CASCADE NOT NULL,
+    role VARCHAR NOT NULL CHECK (role IN ('admin', 'member')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+ALTER TABLE Addresses ADD COLUMN organization_id BIGINT REFERENCES Organizations (id) ON DELETE CASCADE;
+ALTER TABLE Address_merges ADD COLUMN organization_id BIGINT REFERENCES Organizations (id) ON DELETE CASCADE NOT NULL;
+
+CREATE INDEX addresses_organization_id_idx ON Addresses (organization_id, user_id);
+
+ALTER TABLE Addresses ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX addresses_deleted_at_idx ON Addresses (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE Address_versions (
+    address_id BIGINT REFERENCES Addresses (id) ON DELETE CASCADE NOT NULL,
+    version INT NOT NULL,
+    actor_id BIGINT REFERENCES Users (id) ON DELETE SET NULL,
+    action VARCHAR NOT NULL,
+    changes JSONB NOT NULL,
+    name VARCHAR NOT NULL,
+    phone_number VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (address_id, version)
+);
+
+ALTER TABLE Addresses ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE Idempotency_keys (
+    user_id BIGINT REFERENCES Users (id) ON DELETE CASCADE NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR NOT NULL,
+    status_code INT,
+    header JSONB,
+    body BYTEA,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE TABLE Audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT,
+    actor_id BIGINT,
+    action VARCHAR NOT NULL,
+    target VARCHAR NOT NULL,
+    ip VARCHAR NOT NULL,
+    user_agent VARCHAR NOT NULL,
+    outcome VARCHAR NOT NULL CHECK (outcome IN ('success', 'failure')),
+    reason VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_events_organization_id_idx ON Audit_events (organization_id, id);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON Audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TABLE Sessions (
+    id VARCHAR PRIMARY KEY,
+    user_id BIGINT REFERENCES Users (id) ON DELETE CASCADE NOT NULL,
+    device_name VARCHAR NOT NULL,
+    ip VARCHAR NOT NULL,
+    user_agent VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX sessions_user_id_idx ON Sessions (user_id);
+
+ALTER TABLE Users ADD COLUMN display_name VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE Users ADD COLUMN locale VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE Users ADD COLUMN timezone VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE Users ADD COLUMN avatar_url VARCHAR NOT NULL DEFAULT '';
+
+CREATE TABLE Email_changes (
+    user_id BIGINT PRIMARY KEY REFERENCES Users (id) ON DELETE CASCADE,
+    email VARCHAR NOT NULL,
+    token_hash VARCHAR NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE User_identities (
+    issuer VARCHAR NOT NULL,
+    subject VARCHAR NOT NULL,
+    user_id BIGINT REFERENCES Users (id) ON DELETE CASCADE NOT NULL,
+    email VARCHAR NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON User_identities (user_id);
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"template/internal/common"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyLease is how long a request in flight holds its key. A retry
+// after it lapses takes the key over, so a request that never finished
+// does not block its key until the stored response would have expired.
+var IdempotencyLease = time.Minute
+
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims the record's key for its user. It returns
+	// nil when the key was free (or expired) and the stored record otherwise.
+	ReserveIdempotencyKey(context.Context, *common.IdempotencyRecord) (*common.IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the response of the record's request
+	// and keeps it until the record's ExpiresAt.
+	CompleteIdempotencyKey(context.Context, *common.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency replays the stored response of a request carrying an
+// Idempotency-Key the user already used. It must run after Authentication.
+func Idempotency(store IdempotencyStore, ttl time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader("Idempotency-Key")
+		if key == "" {
+			ctx.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest,
+				gin.H{"message": "Idempotency-Key too long"})
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.Error(err)
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(ctx.Request.Method + " " + ctx.Request.URL.RequestURI() + "\n"))
+		hash.Write(body)
+
+		record := &common.IdempotencyRecord{
+			UserID:      ctx.GetInt("user_id"),
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash.Sum(nil)),
+			ExpiresAt:   time.Now().Add(IdempotencyLease),
+		}
+
+		existing, err := store.ReserveIdempotencyKey(ctx, record)
+		if err != nil {
+			ctx.Error(err)
+			ctx.Abort()
+			return
+		}
+
+		if existing != nil {
+			replay(ctx, record, existing)
+			return
+		}
+
+		// Until the response is stored the key is released on the way out,
+		// including when a handler panics, so a retry need not wait for the
+		// lease. Errors are rendered by the Errors middleware after this
+		// returns, so failed requests are not stored.
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := store.ReleaseIdempotencyKey(releaseCtx, record.UserID, record.Key); err != nil {
+				common.Log.Errorf("release idempotency key: %s", err)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: ctx.Writer}
+		ctx.Writer = recorder
+
+		ctx.Next()
+
+		if len(ctx.Errors) > 0 || ctx.Writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+
+		record.StatusCode = ctx.Writer.Status()
+		record.Header = ctx.Writer.Header().Clone()
+		record.Body = recorder.body.Bytes()
+		record.ExpiresAt = time.Now().Add(ttl)
+
+		storeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := store.CompleteIdempotencyKey(storeCtx, record); err != nil {
+			common.Log.Errorf("complete idempotency key: %s", err)
+			return
+		}
+		completed = true
+	}
+}
+
+func replay(ctx *gin.Context, record *common.IdempotencyRecord, existing *common.IdempotencyRecord) {
+	if existing.RequestHash != record.RequestHash {
+		ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity,
+			gin.H{"message": "Idempotency-Key already used for a different request"})
+		return
+	}
+
+	if !existing.Completed() {
+		ctx.AbortWithStatusJSON(http.StatusConflict,
+			gin.H{"message": "a request with this Idempotency-Key is in progress"})
+		return
+	}
+
+	for name, values := range existing.Header {
+		for _, value := range values {
+			ctx.Writer.Header().Add(name, value)
+		}
+	}
+	ctx.Header("Idempotent-Replayed", "true")
+
+	ctx.Writer.WriteHeader(existing.StatusCode)
+	ctx.Writer.Write(existing.Body)
+	ctx.Abort()
+}
@@ -0,0 +1,102 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"template/internal/api"
+	"template/internal/common"
+	"template/internal/db"
+	"template/internal/phonebook"
+	"template/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newIdempotentRouter(t *testing.T, handler gin.HandlerFunc) (*gin.Engine, *repository.SQLiteRepository, int) {
+	t.Helper()
+
+	common.SetLogger(common.NewLogrusLogger())
+
+	conn, err := db.ConnectSQLite(filepath.Join(t.TempDir(), "phonebook.db"))
+	if err != nil {
+		t.Fatalf("ConnectSQLite: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	repo := repository.NewSQLiteRepository(conn)
+
+	userID, err := repo.NewUser(context.Background(), &phonebook.User{Email: "alice@example.com", Password: "password"})
+	if err != nil {
+		t.Fatalf("NewUser: %s", err)
+	}
+
+	signIn := func(ctx *gin.Context) { ctx.Set("user_id", userID) }
+
+	router := api.Setup(api.Route{
+		Method:  "POST",
+		Path:    "/things",
+		Handler: []gin.HandlerFunc{signIn, api.Idempotency(repo, time.Hour), handler},
+	})
+
+	return router, repo, userID
+}
+
+func post(router *gin.Engine, key string) int {
+	req := httptest.NewRequest("POST", "/things", nil)
+	req.Header.Set("Idempotency-Key", key)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w.Code
+}
+
+func TestIdempotencyReleasedAfterPanic(t *testing.T) {
+	fail := true
+	router, _, _ := newIdempotentRouter(t, func(ctx *gin.Context) {
+		if fail {
+			panic("boom")
+		}
+		ctx.Status(http.StatusCreated)
+	})
+
+	if code := post(router, "k"); code != http.StatusInternalServerError {
+		t.Fatalf("panicking request = %d, want 500", code)
+	}
+
+	fail = false
+	if code := post(router, "k"); code != http.StatusCreated {
+		t.Fatalf("retry after a panic = %d, want 201", code)
+	}
+
+	fail = true
+	if code := post(router, "k"); code != http.StatusCreated {
+		t.Fatalf("replay = %d, want the stored 201", code)
+	}
+}
+
+func TestIdempotencyLeaseTakeover(t *testing.T) {
+	router, repo, userID := newIdempotentRouter(t, func(ctx *gin.Context) {
+		ctx.Status(http.StatusCreated)
+	})
+
+	// A request that reserved the key and then vanished without releasing
+	// it, whose lease has lapsed.
+	existing, err := repo.ReserveIdempotencyKey(context.Background(), &common.IdempotencyRecord{
+		UserID:      userID,
+		Key:         "k",
+		RequestHash: "lost",
+		ExpiresAt:   time.Now().Add(-time.Second),
+	})
+	if err != nil || existing != nil {
+		t.Fatalf("ReserveIdempotencyKey = %+v, %v", existing, err)
+	}
+
+	if code := post(router, "k"); code != http.StatusCreated {
+		t.Fatalf("request after the lease lapsed = %d, want 201", code)
+	}
+}
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"template/internal/common"
@@ -43,10 +45,19 @@ func Log() gin.HandlerFunc {
 	}
 }
 
-func Authentication() gin.HandlerFunc {
+type SessionAuthenticator interface {
+	Authenticate(ctx context.Context, userID int, organizationID int, sessionID string) error
+}
+
+// Authentication admits requests bearing a valid token whose session has
+// not been revoked, from users still in the token's organization.
+func Authentication(sessions SessionAuthenticator) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		if gin.Mode() == gin.TestMode {
 			ctx.Set("user_id", 1)
+			ctx.Set("tenant_id", 1)
+			reqCtx := common.WithActorID(ctx.Request.Context(), 1)
+			ctx.Request = ctx.Request.WithContext(common.WithTenantID(reqCtx, 1))
 			ctx.Next()
 			return
 		}
@@ -58,7 +69,23 @@ func Authentication() gin.HandlerFunc {
 			return
 		}
 
-		user_id, err := common.JwtGetID(header[1])
+		claims, err := common.JwtParse(header[1])
+
+		if errors.As(err, &common.AuthenticationError{}) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized,
+				gin.H{"message": "re-login to access this resource"})
+			return
+		}
+
+		if err != nil {
+			ctx.Error(err)
+			ctx.Abort()
+			return
+		}
+
+		user_id, _ := claims.UserID()
+
+		err = sessions.Authenticate(ctx, user_id, claims.TenantID, claims.ID)
 
 		if errors.As(err, &common.AuthenticationError{}) {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized,
@@ -68,9 +95,16 @@ func Authentication() gin.HandlerFunc {
 
 		if err != nil {
 			ctx.Error(err)
+			ctx.Abort()
+			return
 		}
 
 		ctx.Set("user_id", user_id)
+		ctx.Set("tenant_id", claims.TenantID)
+		ctx.Set("session_id", claims.ID)
+		reqCtx := common.WithActorID(ctx.Request.Context(), user_id)
+		reqCtx = common.WithSessionID(reqCtx, claims.ID)
+		ctx.Request = ctx.Request.WithContext(common.WithTenantID(reqCtx, claims.TenantID))
 		ctx.Next()
 	}
 }
@@ -79,13 +113,21 @@ func Errors() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		ctx.Next()
 
+		// A streamed response that failed midway has already sent its
+		// status; Log records the error.
+		if len(ctx.Errors) > 0 && ctx.Writer.Written() {
+			return
+		}
+
 		if len(ctx.Errors) > 0 {
 			var je *json.UnmarshalTypeError
+			var se *json.SyntaxError
 			var ve validator.ValidationErrors
 			var he common.ClientError
 
 			switch {
-			case errors.As(ctx.Errors[0], &je):
+			case errors.As(ctx.Errors[0], &je), errors.As(ctx.Errors[0], &se),
+				errors.Is(ctx.Errors[0], io.EOF), errors.Is(ctx.Errors[0], io.ErrUnexpectedEOF):
 				ctx.AbortWithStatusJSON(http.StatusBadRequest,
 					gin.H{"message": "invalid JSON format"})
 
@@ -108,3 +150,27 @@ func Errors() gin.HandlerFunc {
 		}
 	}
 }
+
+// Primary pins requests that may write to the primary database, so reads
+// after their writes are not served by a lagging replica.
+func Primary() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		switch ctx.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			ctx.Request = ctx.Request.WithContext(common.WithPrimary(ctx.Request.Context()))
+		}
+
+		ctx.Next()
+	}
+}
+
+// Client records the caller's address and user agent in the request
+// context for the audit log.
+func Client() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		client := common.Client{IP: ctx.ClientIP(), UserAgent: ctx.Request.UserAgent()}
+		ctx.Request = ctx.Request.WithContext(common.WithClient(ctx.Request.Context(), client))
+		ctx.Next()
+	}
+}
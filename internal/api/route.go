@@ -12,11 +12,18 @@ type Route struct {
 	Handler []gin.HandlerFunc
 }
 
+// Setup returns a router serving routes. It trusts no proxy, so the client
+// IP is the peer address until SetTrustedProxies names the proxies whose
+// X-Forwarded-For may be believed.
 func Setup(routes ...Route) *gin.Engine {
 	router := gin.New()
+	router.ContextWithFallback = true
+	router.SetTrustedProxies(nil)
 	router.Use(gin.Recovery())
 	router.Use(Log())
 	router.Use(Errors())
+	router.Use(Primary())
+	router.Use(Client())
 
 	for _, route := range routes {
 		router.Handle(route.Method, route.Path, route.Handler...)
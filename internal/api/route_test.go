@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"template/internal/common"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSetupTrustsNoProxy(t *testing.T) {
+	common.SetLogger(common.NewLogrusLogger())
+
+	var got string
+	router := Setup(Route{Method: "GET", Path: "/", Handler: []gin.HandlerFunc{func(ctx *gin.Context) {
+		got = common.ClientFrom(ctx).IP
+	}}})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "192.0.2.1" {
+		t.Fatalf("client IP = %q, want the peer address 192.0.2.1", got)
+	}
+
+	err := router.SetTrustedProxies([]string{"192.0.2.0/24"})
+	if err != nil {
+		t.Fatalf("SetTrustedProxies: %s", err)
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "198.51.100.7" {
+		t.Fatalf("client IP behind a trusted proxy = %q, want 198.51.100.7", got)
+	}
+}
+
+func TestErrorsInvalidJSON(t *testing.T) {
+	common.SetLogger(common.NewLogrusLogger())
+
+	router := Setup(Route{Method: "POST", Path: "/", Handler: []gin.HandlerFunc{func(ctx *gin.Context) {
+		var input struct {
+			Name string `json:"name"`
+		}
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			ctx.Error(err)
+			return
+		}
+	}}})
+
+	for _, body := range []string{"", "{", "{]", `{"name": 1}`} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %q = %d, want 400", body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+
+	value, found, _ := c.Get(ctx, "a")
+	if !found || string(value) != "1" {
+		t.Fatalf("Get(a) = %q, %v, want 1", value, found)
+	}
+
+	// b is now the least recently used and makes room for c.
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, found, _ := c.Get(ctx, "b"); found {
+		t.Fatal("Get(b) found the evicted entry")
+	}
+
+	if c.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", c.Len())
+	}
+
+	c.Set(ctx, "a", []byte("4"), time.Minute)
+
+	value, found, _ = c.Get(ctx, "a")
+	if !found || string(value) != "4" {
+		t.Fatalf("Get(a) after Set = %q, %v, want 4", value, found)
+	}
+
+	c.Delete(ctx, "a", "missing")
+
+	if _, found, _ := c.Get(ctx, "a"); found {
+		t.Fatal("Get(a) found the deleted entry")
+	}
+
+	if c.Len() != 1 {
+		t.Fatalf("Len = %d, want 1", c.Len())
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+
+	c.Set(ctx, "a", []byte("1"), -time.Second)
+
+	if _, found, _ := c.Get(ctx, "a"); found {
+		t.Fatal("Get found an expired entry")
+	}
+
+	if c.Len() != 0 {
+		t.Fatalf("Len = %d, want the expired entry dropped", c.Len())
+	}
+}
@@ -0,0 +1,15 @@
+package common
+
+import "context"
+
+type actorKey struct{}
+
+func WithActorID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// ActorID is the user on whose behalf ctx runs, if any.
+func ActorID(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(actorKey{}).(int)
+	return userID, ok && userID > 0
+}
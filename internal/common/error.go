@@ -2,6 +2,8 @@ package common
 
 import "net/http"
 
+// ClientError is an error with an HTTP status. Its code is the status less
+// 300, so 404 is code 104 and 503 code 203.
 type ClientError interface {
 	HTTPStatus() int
 	Code() int
@@ -71,3 +73,84 @@ func (e NotFoundError) Code() int {
 func (e NotFoundError) Error() string {
 	return e.Message
 }
+
+type PreconditionFailedError struct {
+	Message string
+}
+
+func (e PreconditionFailedError) HTTPStatus() int {
+	return http.StatusPreconditionFailed
+}
+
+func (e PreconditionFailedError) Code() int {
+	return 112
+}
+
+func (e PreconditionFailedError) Error() string {
+	return e.Message
+}
+
+type UnprocessableEntityError struct {
+	Message string
+}
+
+func (e UnprocessableEntityError) HTTPStatus() int {
+	return http.StatusUnprocessableEntity
+}
+
+func (e UnprocessableEntityError) Code() int {
+	return 122
+}
+
+func (e UnprocessableEntityError) Error() string {
+	return e.Message
+}
+
+type ConflictError struct {
+	Message string
+}
+
+func (e ConflictError) HTTPStatus() int {
+	return http.StatusConflict
+}
+
+func (e ConflictError) Code() int {
+	return 109
+}
+
+func (e ConflictError) Error() string {
+	return e.Message
+}
+
+type UnsupportedMediaTypeError struct {
+	Message string
+}
+
+func (e UnsupportedMediaTypeError) HTTPStatus() int {
+	return http.StatusUnsupportedMediaType
+}
+
+func (e UnsupportedMediaTypeError) Code() int {
+	return 115
+}
+
+func (e UnsupportedMediaTypeError) Error() string {
+	return e.Message
+}
+
+// UnavailableError reports a feature the server is not configured for.
+type UnavailableError struct {
+	Message string
+}
+
+func (e UnavailableError) HTTPStatus() int {
+	return http.StatusServiceUnavailable
+}
+
+func (e UnavailableError) Code() int {
+	return 203
+}
+
+func (e UnavailableError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,22 @@
+package common
+
+import (
+	"net/http"
+	"time"
+)
+
+type IdempotencyRecord struct {
+	UserID      int
+	Key         string
+	RequestHash string
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// Completed reports whether the response of the first request was stored.
+// A reserved but not yet completed record means that request is in flight.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
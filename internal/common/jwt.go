@@ -9,15 +9,38 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func JwtGenerate(user_id int) (string, error) {
+// JwtLifetime is how long an issued token is valid.
+var JwtLifetime = time.Hour
+
+type JwtClaims struct {
+	jwt.RegisteredClaims
+	TenantID int `json:"tid,omitempty"`
+}
+
+func (c *JwtClaims) UserID() (int, error) {
+	id, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return -1, AuthenticationError{Message: "JWT ID invalid"}
+	}
+
+	return id, nil
+}
+
+// JwtGenerate signs a token for the user in a tenant. The session ID is
+// carried as the token ID, so revoking the session revokes the token.
+func JwtGenerate(user_id int, tenant_id int, session_id string) (string, error) {
 	now := time.Now()
-	registeredClaims := jwt.RegisteredClaims{
-		Issuer:    config.JWT_ISSUER,
-		IssuedAt:  &jwt.NumericDate{Time: now},
-		ExpiresAt: &jwt.NumericDate{Time: now.Add(time.Hour)},
-		Subject:   strconv.Itoa(user_id),
+	claims := JwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        session_id,
+			Issuer:    config.JWT_ISSUER,
+			IssuedAt:  &jwt.NumericDate{Time: now},
+			ExpiresAt: &jwt.NumericDate{Time: now.Add(JwtLifetime)},
+			Subject:   strconv.Itoa(user_id),
+		},
+		TenantID: tenant_id,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, registeredClaims)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	tokenStr, err := token.SignedString([]byte(config.JWT_SECRET))
 	if err != nil {
@@ -26,9 +49,12 @@ func JwtGenerate(user_id int) (string, error) {
 	return tokenStr, nil
 }
 
-func JwtGetID(tokenStr string) (int, error) {
-	token, err := jwt.Parse(
+func JwtParse(tokenStr string) (*JwtClaims, error) {
+	claims := &JwtClaims{}
+
+	_, err := jwt.ParseWithClaims(
 		tokenStr,
+		claims,
 		func(t *jwt.Token) (interface{}, error) {
 			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 				return -1, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
@@ -40,23 +66,24 @@ func JwtGetID(tokenStr string) (int, error) {
 		jwt.WithIssuer(config.JWT_ISSUER),
 	)
 	if err != nil {
-		return -1, AuthenticationError{Message: "JWT parsing failed"}
+		return nil, AuthenticationError{Message: "JWT parsing failed"}
 	}
 
-	tokenMap, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return -1, AuthenticationError{Message: "JWT claims invalid"}
+	if claims.Subject == "" {
+		return nil, AuthenticationError{Message: "JWT ID missing"}
 	}
 
-	id_str, err := tokenMap.GetSubject()
-	if err != nil {
-		return -1, AuthenticationError{Message: "JWT ID missing"}
+	if _, err := claims.UserID(); err != nil {
+		return nil, err
 	}
 
-	id, err := strconv.Atoi(id_str)
-	if err != nil {
-		return -1, AuthenticationError{Message: "JWT ID invalid"}
+	if claims.TenantID <= 0 {
+		return nil, AuthenticationError{Message: "JWT tenant missing"}
 	}
 
-	return id, nil
+	if claims.ID == "" {
+		return nil, AuthenticationError{Message: "JWT session missing"}
+	}
+
+	return claims, nil
 }
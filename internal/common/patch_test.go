@@ -0,0 +1,171 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func jsonEqual(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result %s: %s", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("want %s: %s", want, err)
+	}
+
+	return reflect.DeepEqual(g, w)
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"replace member", `{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+		{"add member", `{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+		{"null removes", `{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+		{"arrays replace", `{"a": [1, 2]}`, `{"a": [3]}`, `{"a": [3]}`},
+		{"nested", `{"a": {"b": "c", "d": "e"}}`, `{"a": {"d": null, "f": "g"}}`, `{"a": {"b": "c", "f": "g"}}`},
+		{"object over scalar", `{"a": "b"}`, `{"a": {"c": "d"}}`, `{"a": {"c": "d"}}`},
+		{"non-object replaces the document", `{"a": "b"}`, `["c"]`, `["c"]`},
+		{"empty patch", `{"a": "b"}`, `{}`, `{"a": "b"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("MergePatch: %s", err)
+			}
+
+			if !jsonEqual(t, got, tt.want) {
+				t.Fatalf("MergePatch = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	_, err := MergePatch([]byte(`{}`), []byte(`{`))
+	var ie InvariantError
+	if !errors.As(err, &ie) {
+		t.Fatalf("MergePatch of malformed JSON = %v, want an invariant error", err)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	doc := `{"name": "Ann", "tags": ["a", "b"], "a/b": 1, "m~n": 2, "nested": {"x": {"y": 1}}}`
+
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{"add member", `[{"op": "add", "path": "/phone", "value": "1"}]`,
+			`{"name": "Ann", "phone": "1", "tags": ["a", "b"], "a/b": 1, "m~n": 2, "nested": {"x": {"y": 1}}}`},
+		{"add replaces member", `[{"op": "add", "path": "/name", "value": "Bob"}]`,
+			`{"name": "Bob", "tags": ["a", "b"], "a/b": 1, "m~n": 2, "nested": {"x": {"y": 1}}}`},
+		{"add inserts into array", `[{"op": "add", "path": "/tags/1", "value": "c"}]`,
+			`{"name": "Ann", "tags": ["a", "c", "b"], "a/b": 1, "m~n": 2, "nested": {"x": {"y": 1}}}`},
+		{"add appends with -", `[{"op": "add", "path": "/tags/-", "value": "c"}]`,
+			`{"name": "Ann", "tags": ["a", "b", "c"], "a/b": 1, "m~n": 2, "nested": {"x": {"y": 1}}}`},
+		{"remove member", `[{"op": "remove", "path": "/name"}]`,
+			`{"tags": ["a", "b"], "a/b": 1, "m~n": 2, "nested": {"x": {"y": 1}}}`},
+		{"remove array element", `[{"op": "remove", "path": "/tags/0"}]`,
+			`{"name": "Ann", "tags": ["b"], "a/b": 1, "m~n": 2, "nested": {"x": {"y": 1}}}`},
+		{"unescape ~1", `[{"op": "remove", "path": "/a~1b"}]`,
+			`{"name": "Ann", "tags": ["a", "b"], "m~n": 2, "nested": {"x": {"y": 1}}}`},
+		{"unescape ~0", `[{"op": "replace", "path": "/m~0n", "value": 3}]`,
+			`{"name": "Ann", "tags": ["a", "b"], "a/b": 1, "m~n": 3, "nested": {"x": {"y": 1}}}`},
+		{"replace nested", `[{"op": "replace", "path": "/nested/x/y", "value": 2}]`,
+			`{"name": "Ann", "tags": ["a", "b"], "a/b": 1, "m~n": 2, "nested": {"x": {"y": 2}}}`},
+		{"replace root", `[{"op": "replace", "path": "", "value": {"name": "Bob"}}]`,
+			`{"name": "Bob"}`},
+		{"move", `[{"op": "move", "from": "/nested/x", "path": "/x"}]`,
+			`{"name": "Ann", "tags": ["a", "b"], "a/b": 1, "m~n": 2, "nested": {}, "x": {"y": 1}}`},
+		{"move to itself", `[{"op": "move", "from": "/name", "path": "/name"}]`, doc},
+		{"copy is deep", `[{"op": "copy", "from": "/nested", "path": "/copy"}, {"op": "replace", "path": "/copy/x/y", "value": 2}]`,
+			`{"name": "Ann", "tags": ["a", "b"], "a/b": 1, "m~n": 2, "nested": {"x": {"y": 1}}, "copy": {"x": {"y": 2}}}`},
+		{"test deep equality", `[{"op": "test", "path": "/nested", "value": {"x": {"y": 1.0}}}, {"op": "test", "path": "/tags", "value": ["a", "b"]}]`, doc},
+		{"operations apply in order", `[{"op": "add", "path": "/n", "value": 1}, {"op": "test", "path": "/n", "value": 1}, {"op": "remove", "path": "/n"}]`, doc},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("JSONPatch: %s", err)
+			}
+
+			if !jsonEqual(t, got, tt.want) {
+				t.Fatalf("JSONPatch = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	doc := `{"name": "Ann", "tags": ["a", "b"], "nested": {"x": 1}}`
+
+	// Malformed patches are the client's syntax error; patches that are well
+	// formed but do not fit the document cannot be processed.
+	invariant := []struct {
+		name  string
+		patch string
+	}{
+		{"not an array", `{"op": "add"}`},
+		{"malformed JSON", `[`},
+		{"unknown op", `[{"op": "frobnicate", "path": "/name"}]`},
+		{"missing path", `[{"op": "remove"}]`},
+		{"missing value", `[{"op": "add", "path": "/name"}]`},
+		{"missing from", `[{"op": "move", "path": "/name"}]`},
+		{"path without slash", `[{"op": "remove", "path": "name"}]`},
+		{"invalid index", `[{"op": "remove", "path": "/tags/x"}]`},
+		{"leading zero", `[{"op": "remove", "path": "/tags/01"}]`},
+		{"- outside add", `[{"op": "remove", "path": "/tags/-"}]`},
+		{"move into itself", `[{"op": "move", "from": "/nested", "path": "/nested/x/y"}]`},
+	}
+
+	for _, tt := range invariant {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := JSONPatch([]byte(doc), []byte(tt.patch))
+
+			var ie InvariantError
+			if !errors.As(err, &ie) {
+				t.Fatalf("JSONPatch = %v, want an invariant error", err)
+			}
+		})
+	}
+
+	unprocessable := []struct {
+		name  string
+		patch string
+	}{
+		{"test failed", `[{"op": "test", "path": "/name", "value": "Bob"}]`},
+		{"test type mismatch", `[{"op": "test", "path": "/tags", "value": "a"}]`},
+		{"remove missing", `[{"op": "remove", "path": "/phone"}]`},
+		{"replace missing", `[{"op": "replace", "path": "/phone", "value": "1"}]`},
+		{"add under missing parent", `[{"op": "add", "path": "/missing/x", "value": 1}]`},
+		{"add past the end", `[{"op": "add", "path": "/tags/3", "value": "c"}]`},
+		{"remove past the end", `[{"op": "remove", "path": "/tags/2"}]`},
+		{"copy missing", `[{"op": "copy", "from": "/phone", "path": "/x"}]`},
+		{"path through a scalar", `[{"op": "add", "path": "/name/x", "value": 1}]`},
+		{"later operation", `[{"op": "add", "path": "/x", "value": 1}, {"op": "test", "path": "/x", "value": 2}]`},
+	}
+
+	for _, tt := range unprocessable {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := JSONPatch([]byte(doc), []byte(tt.patch))
+
+			var ue UnprocessableEntityError
+			if !errors.As(err, &ue) {
+				t.Fatalf("JSONPatch = %v, want an unprocessable entity error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package common
+
+import "context"
+
+type primaryKey struct{}
+
+// WithPrimary pins the reads made with ctx to the primary database, so they
+// see the writes made before them.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func Primary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
@@ -0,0 +1,15 @@
+package common
+
+import "context"
+
+type sessionKey struct{}
+
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, sessionID)
+}
+
+// SessionID is the session ctx was authenticated with, if any.
+func SessionID(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(sessionKey{}).(string)
+	return sessionID, ok && sessionID != ""
+}
@@ -0,0 +1,14 @@
+package common
+
+import "context"
+
+type tenantKey struct{}
+
+func WithTenantID(ctx context.Context, tenantID int) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+func TenantID(ctx context.Context) (int, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(int)
+	return tenantID, ok && tenantID > 0
+}
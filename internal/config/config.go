@@ -12,6 +12,29 @@ var (
 	DATABASE_HOST = os.Getenv("DATABASE_HOST")
 	DATABASE_PORT = os.Getenv("DATABASE_PORT")
 	DATABASE      = os.Getenv("DATABASE")
+	DATABASE_RLS  = os.Getenv("DATABASE_RLS")
+)
+
+var (
+	DATABASE_DRIVER      = os.Getenv("DATABASE_DRIVER")
+	DATABASE_PATH        = os.Getenv("DATABASE_PATH")
+	DATABASE_SSLMODE     = os.Getenv("DATABASE_SSLMODE")
+	DATABASE_SSLROOTCERT = os.Getenv("DATABASE_SSLROOTCERT")
+	DATABASE_SSLCERT     = os.Getenv("DATABASE_SSLCERT")
+	DATABASE_SSLKEY      = os.Getenv("DATABASE_SSLKEY")
+)
+
+var (
+	DATABASE_MAX_CONNS                = os.Getenv("DATABASE_MAX_CONNS")
+	DATABASE_MIN_CONNS                = os.Getenv("DATABASE_MIN_CONNS")
+	DATABASE_MAX_CONN_LIFETIME        = os.Getenv("DATABASE_MAX_CONN_LIFETIME")
+	DATABASE_MAX_CONN_IDLE_TIME       = os.Getenv("DATABASE_MAX_CONN_IDLE_TIME")
+	DATABASE_STATEMENT_CACHE_CAPACITY = os.Getenv("DATABASE_STATEMENT_CACHE_CAPACITY")
+)
+
+var (
+	DATABASE_REPLICAS               = os.Getenv("DATABASE_REPLICAS")
+	DATABASE_REPLICA_CHECK_INTERVAL = os.Getenv("DATABASE_REPLICA_CHECK_INTERVAL")
 )
 
 var (
@@ -20,5 +43,52 @@ var (
 )
 
 var (
-	PORT string = os.Getenv("PORT")
+	PORT            string = os.Getenv("PORT")
+	TRUSTED_PROXIES string = os.Getenv("TRUSTED_PROXIES")
+	DEBUG_ADDR      string = os.Getenv("DEBUG_ADDR")
+)
+
+var (
+	TRASH_RETENTION      string = os.Getenv("TRASH_RETENTION")
+	TRASH_PURGE_INTERVAL string = os.Getenv("TRASH_PURGE_INTERVAL")
+)
+
+var (
+	IDEMPOTENCY_TTL            string = os.Getenv("IDEMPOTENCY_TTL")
+	IDEMPOTENCY_PURGE_INTERVAL string = os.Getenv("IDEMPOTENCY_PURGE_INTERVAL")
+)
+
+var (
+	CACHE_ENABLED string = os.Getenv("CACHE_ENABLED")
+	CACHE_SIZE    string = os.Getenv("CACHE_SIZE")
+	CACHE_TTL     string = os.Getenv("CACHE_TTL")
+)
+
+var (
+	BATCH_MAX_SIZE string = os.Getenv("BATCH_MAX_SIZE")
+)
+
+var (
+	SESSION_RETENTION      string = os.Getenv("SESSION_RETENTION")
+	SESSION_PURGE_INTERVAL string = os.Getenv("SESSION_PURGE_INTERVAL")
+)
+
+var (
+	SMTP_ADDR     string = os.Getenv("SMTP_ADDR")
+	SMTP_FROM     string = os.Getenv("SMTP_FROM")
+	SMTP_USERNAME string = os.Getenv("SMTP_USERNAME")
+	SMTP_PASSWORD string = os.Getenv("SMTP_PASSWORD")
+	SMTP_LOG      string = os.Getenv("SMTP_LOG")
+)
+
+var (
+	EMAIL_CHANGE_TTL string = os.Getenv("EMAIL_CHANGE_TTL")
+)
+
+var (
+	OIDC_ISSUER        string = os.Getenv("OIDC_ISSUER")
+	OIDC_CLIENT_ID     string = os.Getenv("OIDC_CLIENT_ID")
+	OIDC_CLIENT_SECRET string = os.Getenv("OIDC_CLIENT_SECRET")
+	OIDC_REDIRECT_URL  string = os.Getenv("OIDC_REDIRECT_URL")
+	OIDC_SCOPES        string = os.Getenv("OIDC_SCOPES")
 )
@@ -0,0 +1,97 @@
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL,
+    password TEXT NOT NULL
+);
+
+CREATE TABLE organizations (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE memberships (
+    organization_id INTEGER REFERENCES organizations (id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('admin', 'member')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE TABLE collections (
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    name TEXT NOT NULL
+);
+
+CREATE TABLE collection_shares (
+    id INTEGER PRIMARY KEY,
+    collection_id INTEGER REFERENCES collections (id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    permission TEXT NOT NULL CHECK (permission IN ('viewer', 'editor')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    accepted_at TIMESTAMP,
+    UNIQUE (collection_id, user_id)
+);
+
+CREATE TABLE addresses (
+    id INTEGER PRIMARY KEY,
+    organization_id INTEGER REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    collection_id INTEGER REFERENCES collections (id) ON DELETE SET NULL,
+    name TEXT NOT NULL,
+    phone_number TEXT NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX addresses_organization_id_idx ON addresses (organization_id, user_id);
+CREATE INDEX addresses_deleted_at_idx ON addresses (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE address_versions (
+    address_id INTEGER REFERENCES addresses (id) ON DELETE CASCADE NOT NULL,
+    version INTEGER NOT NULL,
+    actor_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    changes TEXT NOT NULL,
+    name TEXT NOT NULL,
+    phone_number TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (address_id, version)
+);
+
+CREATE TABLE address_merges (
+    id INTEGER PRIMARY KEY,
+    organization_id INTEGER REFERENCES organizations (id) ON DELETE CASCADE NOT NULL,
+    user_id INTEGER REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    survivor_id INTEGER NOT NULL,
+    survivor_version INTEGER NOT NULL,
+    previous TEXT NOT NULL,
+    merged TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    undone_at TIMESTAMP
+);
+
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE address_tags (
+    address_id INTEGER REFERENCES addresses (id) ON DELETE CASCADE NOT NULL,
+    tag_id INTEGER REFERENCES tags (id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (address_id, tag_id)
+);
+
+CREATE TABLE idempotency_keys (
+    user_id INTEGER REFERENCES users (id) ON DELETE CASCADE NOT NULL,
+    key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER,
+    header TEXT,
+    body BLOB,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
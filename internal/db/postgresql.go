@@ -1,20 +1,54 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"net"
+	"net/url"
+	"strings"
 	"template/internal/config"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// DSN builds the connection URL from config. Credentials are escaped, so
+// passwords may contain any character.
+func DSN() string {
+	return dsn(config.DATABASE_HOST)
+}
+
+// dsn builds the connection URL for host, which may carry its own port.
+func dsn(host string) string {
+	if _, _, err := net.SplitHostPort(host); err != nil && config.DATABASE_PORT != "" {
+		host = net.JoinHostPort(host, config.DATABASE_PORT)
+	}
+
+	params := url.Values{}
+	for key, value := range map[string]string{
+		"sslmode":     config.DATABASE_SSLMODE,
+		"sslrootcert": config.DATABASE_SSLROOTCERT,
+		"sslcert":     config.DATABASE_SSLCERT,
+		"sslkey":      config.DATABASE_SSLKEY,
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.DATABASE_USER, config.DATABASE_PASS),
+		Host:     host,
+		Path:     "/" + config.DATABASE,
+		RawQuery: params.Encode(),
+	}
+
+	return u.String()
+}
+
 func ConnectDB() (*sql.DB, error) {
-	DATABASE_USER := config.DATABASE_USER
-	DATABASE_PASS := config.DATABASE_PASS
-	DATABASE_HOST := config.DATABASE_HOST
-	DATABASE_PORT := config.DATABASE_PORT
-	DATABASE := config.DATABASE
-
-	DATABASE_URL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", DATABASE_USER, DATABASE_PASS, DATABASE_HOST, DATABASE_PORT, DATABASE)
-	db, err := sql.Open("pgx", DATABASE_URL)
+	db, err := sql.Open("pgx", DSN())
 	if err != nil {
 		return nil, err
 	}
@@ -26,3 +60,73 @@ func ConnectDB() (*sql.DB, error) {
 
 	return db, nil
 }
+
+// ConnectReplicas opens the comma separated replica hosts. Connections are
+// made lazily, so a replica that is down does not stop startup.
+func ConnectReplicas(hosts string) ([]*sql.DB, error) {
+	res := make([]*sql.DB, 0)
+
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+
+		db, err := sql.Open("pgx", dsn(host))
+		if err != nil {
+			for _, opened := range res {
+				opened.Close()
+			}
+			return nil, err
+		}
+
+		res = append(res, db)
+	}
+
+	return res, nil
+}
+
+// PoolConfig overrides pgxpool defaults. Zero values keep the default.
+type PoolConfig struct {
+	MaxConns               int
+	MinConns               int
+	MaxConnLifetime        time.Duration
+	MaxConnIdleTime        time.Duration
+	StatementCacheCapacity int
+}
+
+func ConnectPool(ctx context.Context, cfg PoolConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(DSN())
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = int32(cfg.MaxConns)
+	}
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = int32(cfg.MinConns)
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+	if cfg.StatementCacheCapacity > 0 {
+		poolConfig.ConnConfig.StatementCacheCapacity = cfg.StatementCacheCapacity
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pool.Ping(ctx)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
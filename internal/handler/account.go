@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"template/internal/phonebook"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProfileJSON struct {
+	ID          int    `json:"id"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Locale      string `json:"locale"`
+	Timezone    string `json:"timezone"`
+	AvatarURL   string `json:"avatar_url"`
+	HasPassword bool   `json:"has_password"`
+}
+
+type ProfilePatchJSON struct {
+	DisplayName *string `json:"display_name"`
+	Locale      *string `json:"locale"`
+	Timezone    *string `json:"timezone"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+type PasswordChangeJSON struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type EmailChangeJSON struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password"`
+}
+
+type EmailConfirmJSON struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// AccountDeleteJSON confirms an account deletion with the password, or with
+// the account's email address in Confirm when it has no password.
+type AccountDeleteJSON struct {
+	Password string `json:"password"`
+	Confirm  string `json:"confirm"`
+}
+
+type AccountService interface {
+	Profile(ctx context.Context, userID int) (*phonebook.User, error)
+	UpdateProfile(ctx context.Context, userID int, patch *phonebook.ProfilePatch) (*phonebook.User, error)
+	ChangePassword(ctx context.Context, userID int, current string, password string) error
+	RequestEmailChange(ctx context.Context, userID int, email string, password string) error
+	ConfirmEmailChange(ctx context.Context, userID int, token string) (*phonebook.User, error)
+	Export(ctx context.Context, userID int, w io.Writer) error
+	DeleteAccount(ctx context.Context, userID int, password string, confirm string) error
+}
+
+type AccountHandler struct {
+	accountSvc AccountService
+}
+
+func NewAccountHandler(accountSvc AccountService) AccountHandler {
+	return AccountHandler{accountSvc}
+}
+
+func newProfileJSON(user *phonebook.User) ProfileJSON {
+	return ProfileJSON{
+		ID:          user.ID,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		Locale:      user.Locale,
+		Timezone:    user.Timezone,
+		AvatarURL:   user.AvatarURL,
+		HasPassword: user.HasPassword(),
+	}
+}
+
+func (h *AccountHandler) Profile(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	user, err := h.accountSvc.Profile(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": newProfileJSON(user)},
+	)
+}
+
+// UpdateProfile changes the profile fields present in the body. The email
+// address is changed through RequestEmailChange instead.
+func (h *AccountHandler) UpdateProfile(ctx *gin.Context) {
+	var input ProfilePatchJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	user, err := h.accountSvc.UpdateProfile(ctx, userID, &phonebook.ProfilePatch{
+		DisplayName: input.DisplayName,
+		Locale:      input.Locale,
+		Timezone:    input.Timezone,
+		AvatarURL:   input.AvatarURL,
+	})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": newProfileJSON(user)},
+	)
+}
+
+func (h *AccountHandler) ChangePassword(ctx *gin.Context) {
+	var input PasswordChangeJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	err := h.accountSvc.ChangePassword(ctx, userID, input.CurrentPassword, input.NewPassword)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+// RequestEmailChange mails a confirmation token to the new address; the
+// account keeps its email until ConfirmEmailChange is called.
+func (h *AccountHandler) RequestEmailChange(ctx *gin.Context) {
+	var input EmailChangeJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	err := h.accountSvc.RequestEmailChange(ctx, userID, input.Email, input.Password)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusAccepted,
+		gin.H{"message": "confirmation sent"},
+	)
+}
+
+func (h *AccountHandler) ConfirmEmailChange(ctx *gin.Context) {
+	var input EmailConfirmJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	user, err := h.accountSvc.ConfirmEmailChange(ctx, userID, input.Token)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": newProfileJSON(user)},
+	)
+}
+
+// Export downloads a zip archive of everything stored about the caller.
+func (h *AccountHandler) Export(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	var buf bytes.Buffer
+	err := h.accountSvc.Export(ctx, userID, &buf)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", `attachment; filename="account-export.zip"`)
+	ctx.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// DeleteAccount deletes the caller's account and data for good. The
+// password, or the email address without one, confirms the request.
+func (h *AccountHandler) DeleteAccount(ctx *gin.Context) {
+	var input AccountDeleteJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	err := h.accountSvc.DeleteAccount(ctx, userID, input.Password, input.Confirm)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "account deleted"},
+	)
+}
@@ -0,0 +1,129 @@
+package handler_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"template/internal/api"
+	"template/internal/common"
+	"template/internal/handler"
+	"template/internal/phonebook"
+	"testing"
+)
+
+func TestAccountExport(t *testing.T) {
+	router, repo := newRESTRouter(t)
+
+	h := handler.NewAccountHandler(phonebook.NewAccountService(repo, nil, phonebook.DefaultEmailChangeTTL))
+	auth := api.Authentication(phonebook.NewSessionService(repo))
+	router.Handle("POST", "/me/export", auth, h.Export)
+
+	ctx := common.WithActorID(common.WithTenantID(context.Background(), 1), 1)
+
+	address := &phonebook.Address{User: &phonebook.User{ID: 1}, Name: "Carol", PhoneNumber: "+1 555 0100"}
+	err := repo.NewAddress(ctx, address)
+	if err != nil {
+		t.Fatalf("NewAddress: %s", err)
+	}
+
+	tag := &phonebook.Tag{User: &phonebook.User{ID: 1}, Name: "friends"}
+	err = repo.NewTag(ctx, tag)
+	if err != nil {
+		t.Fatalf("NewTag: %s", err)
+	}
+
+	err = repo.TagAddresses(ctx, []int{tag.ID}, []int{address.ID})
+	if err != nil {
+		t.Fatalf("TagAddresses: %s", err)
+	}
+
+	w := serve(router, "PUT", "/addresses/"+strconv.Itoa(address.ID), `{"name": "Carol B", "phone_number": "+1 555 0100"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT = %d %s, want 200", w.Code, w.Body)
+	}
+
+	w = serve(router, "POST", "/me/export", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /me/export = %d %s, want 200", w.Code, w.Body)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("zip: %s", err)
+	}
+
+	f, err := archive.Open("account.json")
+	if err != nil {
+		t.Fatalf("account.json: %s", err)
+	}
+	defer f.Close()
+
+	var data phonebook.AccountExport
+	err = json.NewDecoder(f).Decode(&data)
+	if err != nil {
+		t.Fatalf("decode account.json: %s", err)
+	}
+
+	if len(data.Organizations) != 1 || len(data.Organizations[0].Addresses) != 1 {
+		t.Fatalf("exported organizations = %+v, want one with Carol", data.Organizations)
+	}
+
+	exported := data.Organizations[0].Addresses[0]
+	if len(exported.Tags) != 1 || exported.Tags[0] != "friends" {
+		t.Fatalf("exported tags = %v, want friends", exported.Tags)
+	}
+
+	if len(exported.Versions) != 2 || exported.Versions[0].Action != phonebook.ActionCreate || exported.Versions[1].Action != phonebook.ActionUpdate {
+		t.Fatalf("exported versions = %+v, want create then update", exported.Versions)
+	}
+
+	if change := exported.Versions[1].Changes[phonebook.ColumnName]; change.From != "Carol" || change.To != "Carol B" {
+		t.Fatalf("exported update = %+v, want Carol -> Carol B", change)
+	}
+}
+
+func TestEmailChangeWithoutMailer(t *testing.T) {
+	router, repo := newRESTRouter(t)
+
+	h := handler.NewAccountHandler(phonebook.NewAccountService(repo, nil, phonebook.DefaultEmailChangeTTL))
+	auth := api.Authentication(phonebook.NewSessionService(repo))
+	router.Handle("POST", "/me/email", auth, h.RequestEmailChange)
+
+	w := serve(router, "POST", "/me/email", `{"email": "alice@example.org", "password": "password"}`)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("POST /me/email = %d %s, want 503", w.Code, w.Body)
+	}
+
+	_, err := repo.GetEmailChange(context.Background(), 1)
+	if err == nil {
+		t.Fatal("an email change was stored without a mailer to confirm it")
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	router, repo := newRESTRouter(t)
+
+	h := handler.NewAccountHandler(phonebook.NewAccountService(repo, nil, phonebook.DefaultEmailChangeTTL))
+	auth := api.Authentication(phonebook.NewSessionService(repo))
+	router.Handle("DELETE", "/me", auth, h.DeleteAccount)
+
+	for _, body := range []string{"", "{}", `{"password": "wrong"}`, `{"confirm": "alice@example.com"}`} {
+		w := serve(router, "DELETE", "/me", body)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("DELETE /me %q = %d %s, want 400", body, w.Code, w.Body)
+		}
+	}
+
+	w := serve(router, "DELETE", "/me", `{"password": "password"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE /me with the password = %d %s, want 200", w.Code, w.Body)
+	}
+
+	_, err := repo.GetUserByEmail(context.Background(), "alice@example.com")
+	if err == nil {
+		t.Fatal("the account survived its deletion")
+	}
+}
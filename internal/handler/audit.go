@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditService interface {
+	Events(ctx context.Context, userID int, filter *phonebook.AuditFilter) ([]*phonebook.AuditEvent, error)
+	Export(ctx context.Context, userID int, filter *phonebook.AuditFilter, w io.Writer) error
+}
+
+type AuditHandler struct {
+	auditSvc AuditService
+}
+
+func NewAuditHandler(auditSvc AuditService) AuditHandler {
+	return AuditHandler{auditSvc}
+}
+
+// Events lists the audit log of the caller's organization, newest first.
+// Pass the last ID seen as before_id for the next page.
+func (h *AuditHandler) Events(ctx *gin.Context) {
+	filter, err := auditFilter(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	events, err := h.auditSvc.Events(ctx, ctx.GetInt("user_id"), filter)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": events},
+	)
+}
+
+// Export downloads the matching audit log as JSON lines.
+func (h *AuditHandler) Export(ctx *gin.Context) {
+	filter, err := auditFilter(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	w := &download{ctx: ctx, contentType: "application/x-ndjson", filename: "audit.jsonl"}
+	err = h.auditSvc.Export(ctx, ctx.GetInt("user_id"), filter, w)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	w.start()
+}
+
+// download streams a response body as an attachment. The headers go out
+// with the first write, so an error before any output is still answered
+// with a JSON error; one after it can only cut the download short.
+type download struct {
+	ctx         *gin.Context
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (d *download) start() {
+	if d.started {
+		return
+	}
+	d.started = true
+
+	d.ctx.Header("Content-Type", d.contentType)
+	d.ctx.Header("Content-Disposition", `attachment; filename="`+d.filename+`"`)
+	d.ctx.Status(http.StatusOK)
+	d.ctx.Writer.WriteHeaderNow()
+}
+
+func (d *download) Write(p []byte) (int, error) {
+	d.start()
+	return d.ctx.Writer.Write(p)
+}
+
+func auditFilter(ctx *gin.Context) (*phonebook.AuditFilter, error) {
+	filter := &phonebook.AuditFilter{
+		OrganizationID: ctx.GetInt("tenant_id"),
+		Action:         phonebook.AuditAction(ctx.Query("action")),
+		Outcome:        phonebook.AuditOutcome(ctx.Query("outcome")),
+	}
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"actor_id", &filter.ActorID},
+		{"before_id", &filter.BeforeID},
+		{"limit", &filter.Limit},
+	}
+	for _, param := range ints {
+		value := ctx.Query(param.name)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, common.InvariantError{Message: param.name + " must be a number"}
+		}
+		*param.dst = n
+	}
+
+	times := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	}
+	for _, param := range times {
+		value := ctx.Query(param.name)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, common.InvariantError{Message: param.name + " must be an RFC 3339 time"}
+		}
+		*param.dst = t
+	}
+
+	return filter, nil
+}
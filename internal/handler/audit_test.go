@@ -0,0 +1,71 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"template/internal/api"
+	"template/internal/common"
+	"template/internal/handler"
+	"template/internal/phonebook"
+	"testing"
+)
+
+func TestAuditExport(t *testing.T) {
+	router, repo := newRESTRouter(t)
+
+	h := handler.NewAuditHandler(phonebook.NewAuditService(repo))
+	auth := api.Authentication(phonebook.NewSessionService(repo))
+	router.Handle("GET", "/audit/export", auth, h.Export)
+
+	address := &phonebook.Address{User: &phonebook.User{ID: 1}, Name: "Carol", PhoneNumber: "+1 555 0100"}
+	err := repo.NewAddress(common.WithTenantID(context.Background(), 1), address)
+	if err != nil {
+		t.Fatalf("NewAddress: %s", err)
+	}
+
+	w := serve(router, "DELETE", "/addresses/"+strconv.Itoa(address.ID), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE = %d %s, want 200", w.Code, w.Body)
+	}
+
+	w = serve(router, "DELETE", "/addresses/999", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("DELETE /addresses/999 = %d, want 404", w.Code)
+	}
+
+	w = serve(router, "GET", "/audit/export", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /audit/export = %d %s, want 200", w.Code, w.Body)
+	}
+
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q, want application/x-ndjson", got)
+	}
+
+	var events []phonebook.AuditEvent
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var event phonebook.AuditEvent
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			t.Fatalf("line %q: %s", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 2 || events[0].Outcome != phonebook.AuditFailure || events[1].Outcome != phonebook.AuditSuccess {
+		t.Fatalf("exported events = %+v, want the failed and the successful delete", events)
+	}
+
+	w = serve(router, "GET", "/audit/export?limit=x", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("GET /audit/export?limit=x = %d, want 400", w.Code)
+	}
+
+	if got := w.Header().Get("Content-Disposition"); got != "" {
+		t.Fatalf("Content-Disposition of an error = %q, want none", got)
+	}
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"template/internal/common"
+	"template/internal/phonebook"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+)
+
+type BatchJSON struct {
+	Mode       string               `json:"mode" binding:"omitempty,oneof=atomic best_effort"`
+	Operations []BatchOperationJSON `json:"operations" binding:"required"`
+}
+
+type BatchOperationJSON struct {
+	Op          string `json:"op" binding:"required"`
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+	Version     int    `json:"version"`
+}
+
+type BatchResultJSON struct {
+	Index   int    `json:"index"`
+	Op      string `json:"op"`
+	Status  int    `json:"status"`
+	ID      int    `json:"id,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Batch applies a list of create, update and delete operations. In atomic
+// mode (the default) either every operation is applied or none is, and the
+// response status is that of the operation that failed. In best_effort mode
+// each operation succeeds or fails on its own.
+func (h *RESTHandler) Batch(ctx *gin.Context) {
+	var input BatchJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+	atomic := input.Mode != BatchModeBestEffort
+
+	ops := make([]*phonebook.BatchOperation, 0, len(input.Operations))
+	for _, op := range input.Operations {
+		ops = append(ops, &phonebook.BatchOperation{
+			Op: phonebook.BatchOp(op.Op),
+			Address: &phonebook.Address{
+				ID:          op.ID,
+				Name:        op.Name,
+				PhoneNumber: op.PhoneNumber,
+				Version:     op.Version,
+			},
+		})
+	}
+
+	results, err := h.addressSvc.Batch(ctx, userID, ops, atomic)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	status := http.StatusOK
+	message := "success"
+	res := make([]BatchResultJSON, 0, len(results))
+	for i, result := range results {
+		item := BatchResultJSON{
+			Index:   i,
+			Op:      string(result.Op),
+			ID:      result.Address.ID,
+			Version: result.Address.Version,
+		}
+
+		var ce common.ClientError
+		switch {
+		case result.Err == nil && result.Applied && result.Op == phonebook.BatchCreate:
+			item.Status = http.StatusCreated
+		case result.Err == nil && result.Applied:
+			item.Status = http.StatusOK
+		case result.Err == nil:
+			item.Status = http.StatusFailedDependency
+			item.Error = "rolled back"
+		case errors.As(result.Err, &ce):
+			item.Status = ce.HTTPStatus()
+			item.Error = ce.Error()
+		default:
+			common.Log.Errorf("batch operation %d: %s", i, result.Err)
+			item.Status = http.StatusInternalServerError
+			item.Error = "internal server error"
+		}
+
+		if atomic && result.Err != nil && status == http.StatusOK {
+			status = item.Status
+			message = "batch rolled back"
+		}
+
+		res = append(res, item)
+	}
+
+	ctx.JSON(
+		status,
+		gin.H{"message": message, "data": res},
+	)
+}
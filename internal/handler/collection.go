@@ -0,0 +1,382 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"template/internal/phonebook"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CollectionJSON struct {
+	ID      int    `json:"id"`
+	OwnerID int    `json:"owner_id"`
+	Name    string `json:"name" binding:"required"`
+}
+
+type InviteJSON struct {
+	Email      string `json:"email" binding:"required"`
+	Permission string `json:"permission" binding:"required,oneof=viewer editor"`
+}
+
+type ShareJSON struct {
+	ID         int            `json:"id"`
+	Collection CollectionJSON `json:"collection"`
+	UserID     int            `json:"user_id"`
+	Email      string         `json:"email"`
+	Permission string         `json:"permission"`
+	CreatedAt  time.Time      `json:"created_at"`
+	AcceptedAt *time.Time     `json:"accepted_at"`
+}
+
+type CollectionService interface {
+	NewCollection(ctx context.Context, userID int, collection *phonebook.Collection) error
+	GetCollectionsByUserID(ctx context.Context, userID int) ([]*phonebook.Collection, error)
+	GetCollectionByID(ctx context.Context, userID int, collectionID int) (*phonebook.Collection, error)
+	DeleteCollection(ctx context.Context, userID int, collectionID int) error
+	GetAddressesByCollectionID(ctx context.Context, userID int, collectionID int) ([]*phonebook.Address, error)
+	NewAddress(ctx context.Context, userID int, collectionID int, address *phonebook.Address) error
+	AddAddress(ctx context.Context, userID int, collectionID int, addressID int) error
+	RemoveAddress(ctx context.Context, userID int, collectionID int, addressID int) error
+	Invite(ctx context.Context, userID int, collectionID int, email string, permission phonebook.Permission) (*phonebook.Share, error)
+	GetSharesByCollectionID(ctx context.Context, userID int, collectionID int) ([]*phonebook.Share, error)
+	Invitations(ctx context.Context, userID int) ([]*phonebook.Share, error)
+	AcceptInvitation(ctx context.Context, userID int, shareID int) error
+	RevokeShare(ctx context.Context, userID int, shareID int) error
+}
+
+type CollectionHandler struct {
+	collectionSvc CollectionService
+}
+
+func NewCollectionHandler(collectionSvc CollectionService) CollectionHandler {
+	return CollectionHandler{collectionSvc}
+}
+
+func newShareJSON(share *phonebook.Share) ShareJSON {
+	return ShareJSON{
+		ID: share.ID,
+		Collection: CollectionJSON{
+			ID:      share.Collection.ID,
+			OwnerID: share.Collection.Owner.ID,
+			Name:    share.Collection.Name,
+		},
+		UserID:     share.User.ID,
+		Email:      share.User.Email,
+		Permission: string(share.Permission),
+		CreatedAt:  share.CreatedAt,
+		AcceptedAt: share.AcceptedAt,
+	}
+}
+
+func (h *CollectionHandler) NewCollection(ctx *gin.Context) {
+	var input CollectionJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	collection := &phonebook.Collection{Name: input.Name}
+
+	err := h.collectionSvc.NewCollection(ctx, userID, collection)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusCreated,
+		gin.H{"message": "success", "data": CollectionJSON{
+			ID:      collection.ID,
+			OwnerID: collection.Owner.ID,
+			Name:    collection.Name,
+		}},
+	)
+}
+
+func (h *CollectionHandler) Collections(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	collections, err := h.collectionSvc.GetCollectionsByUserID(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	collectionsResponse := make([]CollectionJSON, 0)
+	for _, collection := range collections {
+		collectionsResponse = append(collectionsResponse, CollectionJSON{
+			ID:      collection.ID,
+			OwnerID: collection.Owner.ID,
+			Name:    collection.Name,
+		})
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": collectionsResponse},
+	)
+}
+
+func (h *CollectionHandler) GetCollectionByID(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	collectionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	collection, err := h.collectionSvc.GetCollectionByID(ctx, userID, collectionID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": CollectionJSON{
+			ID:      collection.ID,
+			OwnerID: collection.Owner.ID,
+			Name:    collection.Name,
+		}},
+	)
+}
+
+func (h *CollectionHandler) DeleteCollection(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	collectionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = h.collectionSvc.DeleteCollection(ctx, userID, collectionID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+func (h *CollectionHandler) Addresses(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	collectionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	addresses, err := h.collectionSvc.GetAddressesByCollectionID(ctx, userID, collectionID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	addressesResponse := make([]AddressJSON, 0)
+	for _, address := range addresses {
+		addressesResponse = append(addressesResponse, AddressJSON{
+			ID:          address.ID,
+			UserID:      address.User.ID,
+			Name:        address.Name,
+			PhoneNumber: address.PhoneNumber,
+		})
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": addressesResponse},
+	)
+}
+
+func (h *CollectionHandler) NewAddress(ctx *gin.Context) {
+	var input AddressJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	collectionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	address := &phonebook.Address{
+		Name:        input.Name,
+		PhoneNumber: input.PhoneNumber,
+	}
+
+	err = h.collectionSvc.NewAddress(ctx, userID, collectionID, address)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusCreated,
+		gin.H{"message": "success"},
+	)
+}
+
+func (h *CollectionHandler) AddAddress(ctx *gin.Context) {
+	h.moveAddress(ctx, h.collectionSvc.AddAddress)
+}
+
+func (h *CollectionHandler) RemoveAddress(ctx *gin.Context) {
+	h.moveAddress(ctx, h.collectionSvc.RemoveAddress)
+}
+
+func (h *CollectionHandler) moveAddress(ctx *gin.Context, move func(ctx context.Context, userID int, collectionID int, addressID int) error) {
+	userID := ctx.GetInt("user_id")
+
+	collectionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	addressID, err := strconv.Atoi(ctx.Param("address_id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = move(ctx, userID, collectionID, addressID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+func (h *CollectionHandler) Invite(ctx *gin.Context) {
+	var input InviteJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	collectionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	share, err := h.collectionSvc.Invite(ctx, userID, collectionID, input.Email, phonebook.Permission(input.Permission))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusCreated,
+		gin.H{"message": "success", "data": newShareJSON(share)},
+	)
+}
+
+func (h *CollectionHandler) Shares(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	collectionID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	shares, err := h.collectionSvc.GetSharesByCollectionID(ctx, userID, collectionID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	sharesResponse := make([]ShareJSON, 0)
+	for _, share := range shares {
+		sharesResponse = append(sharesResponse, newShareJSON(share))
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": sharesResponse},
+	)
+}
+
+func (h *CollectionHandler) Invitations(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	shares, err := h.collectionSvc.Invitations(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	sharesResponse := make([]ShareJSON, 0)
+	for _, share := range shares {
+		sharesResponse = append(sharesResponse, newShareJSON(share))
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": sharesResponse},
+	)
+}
+
+func (h *CollectionHandler) AcceptInvitation(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	shareID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = h.collectionSvc.AcceptInvitation(ctx, userID, shareID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+func (h *CollectionHandler) RevokeShare(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	shareID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = h.collectionSvc.RevokeShare(ctx, userID, shareID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"template/internal/phonebook"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MergeJSON struct {
+	SurvivorID   int    `json:"survivor_id" binding:"required"`
+	DuplicateIDs []int  `json:"duplicate_ids" binding:"required,min=1"`
+	Name         string `json:"name"`
+	PhoneNumber  string `json:"phone_number"`
+}
+
+type DuplicateClusterJSON struct {
+	Reasons   []string      `json:"reasons"`
+	Addresses []AddressJSON `json:"addresses"`
+}
+
+type DuplicateService interface {
+	FindDuplicates(ctx context.Context, userID int) ([]*phonebook.DuplicateCluster, error)
+	Merge(ctx context.Context, userID int, survivorID int, duplicateIDs []int, override *phonebook.Address) (*phonebook.Merge, error)
+	UndoMerge(ctx context.Context, userID int, mergeID int) error
+}
+
+type DuplicateHandler struct {
+	duplicateSvc DuplicateService
+}
+
+func NewDuplicateHandler(duplicateSvc DuplicateService) DuplicateHandler {
+	return DuplicateHandler{duplicateSvc}
+}
+
+func (h *DuplicateHandler) Duplicates(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	clusters, err := h.duplicateSvc.FindDuplicates(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	clustersResponse := make([]DuplicateClusterJSON, 0)
+	for _, cluster := range clusters {
+		addressesResponse := make([]AddressJSON, 0)
+		for _, address := range cluster.Addresses {
+			addressesResponse = append(addressesResponse, AddressJSON{
+				ID:          address.ID,
+				UserID:      address.User.ID,
+				Name:        address.Name,
+				PhoneNumber: address.PhoneNumber,
+			})
+		}
+
+		clustersResponse = append(clustersResponse, DuplicateClusterJSON{
+			Reasons:   cluster.Reasons,
+			Addresses: addressesResponse,
+		})
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": clustersResponse},
+	)
+}
+
+func (h *DuplicateHandler) Merge(ctx *gin.Context) {
+	var input MergeJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	override := &phonebook.Address{
+		Name:        input.Name,
+		PhoneNumber: input.PhoneNumber,
+	}
+
+	merge, err := h.duplicateSvc.Merge(ctx, userID, input.SurvivorID, input.DuplicateIDs, override)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": gin.H{
+			"merge_id": merge.ID,
+			"address": AddressJSON{
+				ID:          merge.Survivor.ID,
+				UserID:      merge.Survivor.User.ID,
+				Name:        merge.Survivor.Name,
+				PhoneNumber: merge.Survivor.PhoneNumber,
+			},
+		}},
+	)
+}
+
+func (h *DuplicateHandler) UndoMerge(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	mergeID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = h.duplicateSvc.UndoMerge(ctx, userID, mergeID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+func etagVersion(tag string) (int, bool) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// ifMatchVersions returns the versions the If-Match header names. It is nil
+// when there is no precondition, and empty when the header names no version
+// of ours. If-Match uses the strong comparison, so weak tags never match.
+func ifMatchVersions(ctx *gin.Context) []int {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	versions := []int{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+
+		if version, ok := etagVersion(tag); ok {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions
+}
+
+// ifMatchVersion turns the If-Match versions into the version a write
+// expects given current, the version it is about to overwrite. It is 0 when
+// there is no precondition, and -1 when none of them is current so the
+// write fails with 412.
+func ifMatchVersion(versions []int, current int) int {
+	if versions == nil {
+		return 0
+	}
+
+	for _, version := range versions {
+		if version == current {
+			return version
+		}
+	}
+
+	return -1
+}
+
+func ifNoneMatch(ctx *gin.Context, version int) bool {
+	header := strings.TrimSpace(ctx.GetHeader("If-None-Match"))
+	if header == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		if v, ok := etagVersion(tag); ok && v == version {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"template/internal/common"
+	"template/internal/oidc"
+	"template/internal/phonebook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcCookie carries the sealed login state from Login to Callback. Its
+// path scopes it to the callback, so no other request carries it.
+const (
+	oidcCookie     = "oidc_login"
+	oidcCookiePath = "/login/oidc/callback"
+)
+
+type IdentityProvider interface {
+	Begin(ctx context.Context, device string) (string, *oidc.LoginState, error)
+	SealState(state *oidc.LoginState) (string, error)
+	OpenState(value string) (*oidc.LoginState, error)
+	Exchange(ctx context.Context, code string, state *oidc.LoginState) (*oidc.Identity, error)
+}
+
+type IdentityService interface {
+	LoginWithIdentity(ctx context.Context, identity *phonebook.Identity, device string) (string, error)
+}
+
+type OIDCHandler struct {
+	provider IdentityProvider
+	userSvc  IdentityService
+}
+
+func NewOIDCHandler(provider IdentityProvider, userSvc IdentityService) OIDCHandler {
+	return OIDCHandler{provider, userSvc}
+}
+
+// Login redirects the browser to the identity provider. The optional
+// device_name query parameter names the session like it does for /login.
+func (h *OIDCHandler) Login(ctx *gin.Context) {
+	url, state, err := h.provider.Begin(ctx, ctx.Query("device_name"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	value, err := h.provider.SealState(state)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcCookie, value, int(oidc.LoginTimeout.Seconds()), oidcCookiePath, "", secureRequest(ctx), true)
+	ctx.Redirect(http.StatusFound, url)
+}
+
+// Callback finishes a sign in started by Login and responds with an access
+// token like /login does.
+func (h *OIDCHandler) Callback(ctx *gin.Context) {
+	value, cookieErr := ctx.Cookie(oidcCookie)
+
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcCookie, "", -1, oidcCookiePath, "", secureRequest(ctx), true)
+
+	if reason := ctx.Query("error"); reason != "" {
+		message := strings.TrimSpace("identity provider refused sign in: " + reason + " " + ctx.Query("error_description"))
+		ctx.Error(common.AuthenticationError{Message: message})
+		return
+	}
+
+	if cookieErr != nil {
+		ctx.Error(common.AuthenticationError{Message: "sign in expired, start again"})
+		return
+	}
+
+	state, err := h.provider.OpenState(value)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(state.State), []byte(ctx.Query("state"))) != 1 {
+		ctx.Error(common.AuthenticationError{Message: "sign in state mismatch, start again"})
+		return
+	}
+
+	identity, err := h.provider.Exchange(ctx, ctx.Query("code"), state)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	token, err := h.userSvc.LoginWithIdentity(ctx, &phonebook.Identity{
+		Issuer:        identity.Issuer,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Name:          identity.Name,
+	}, state.Device)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Header("Authorization", "Bearer "+token)
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "access_token": token},
+	)
+}
+
+// secureRequest reports whether the client reached us over HTTPS, directly
+// or through a TLS terminating proxy. X-Forwarded-Proto is only believed
+// from a proxy the router trusts: gin takes the client IP from
+// X-Forwarded-For for those alone, so it then differs from the peer address.
+func secureRequest(ctx *gin.Context) bool {
+	if ctx.Request.TLS != nil {
+		return true
+	}
+
+	return ctx.GetHeader("X-Forwarded-Proto") == "https" && ctx.ClientIP() != ctx.RemoteIP()
+}
@@ -0,0 +1,206 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"template/internal/api"
+	"template/internal/handler"
+	"template/internal/oidc"
+	"template/internal/oidc/oidctest"
+	"template/internal/phonebook"
+	"template/internal/repository"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const oidcRedirectURL = "http://phonebook.test/login/oidc/callback"
+
+// oidcLogin signs in through the mock provider like a browser would and
+// returns the callback's response. tamper may change the callback URL.
+func oidcLogin(t *testing.T, router *gin.Engine, tamper func(callback *url.URL)) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := serve(router, "GET", "/login/oidc?device_name=laptop", "")
+	if w.Code != http.StatusFound {
+		t.Fatalf("GET /login/oidc = %d %s, want 302", w.Code, w.Body)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("GET /login/oidc set %d cookies, want the login state", len(cookies))
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	res, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize: %s", err)
+	}
+	res.Body.Close()
+
+	callback, err := res.Location()
+	if err != nil {
+		t.Fatalf("authorize redirect: %s", err)
+	}
+
+	if tamper != nil {
+		tamper(callback)
+	}
+
+	req := httptest.NewRequest("GET", callback.RequestURI(), nil)
+	req.AddCookie(cookies[0])
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+// newOIDCRouter serves the OIDC routes against a mock provider.
+func newOIDCRouter(t *testing.T) (*gin.Engine, *oidctest.Provider, *repository.SQLiteRepository) {
+	t.Helper()
+
+	repo := newRepository(t)
+
+	provider, srv, err := oidctest.NewServer("phonebook", "secret")
+	if err != nil {
+		t.Fatalf("oidctest: %s", err)
+	}
+	t.Cleanup(srv.Close)
+
+	provider.SetUser(oidctest.User{Subject: "42", Email: "ann@example.com", EmailVerified: true, Name: "Ann"})
+
+	rp := oidc.New(oidc.Config{
+		Issuer:       srv.URL,
+		ClientID:     "phonebook",
+		ClientSecret: "secret",
+		RedirectURL:  oidcRedirectURL,
+		StateKey:     []byte("test"),
+	})
+
+	h := handler.NewOIDCHandler(rp, phonebook.NewUserService(repo))
+	router := api.Setup(
+		api.Route{Method: "GET", Path: "/login/oidc", Handler: []gin.HandlerFunc{h.Login}},
+		api.Route{Method: "GET", Path: "/login/oidc/callback", Handler: []gin.HandlerFunc{h.Callback}},
+	)
+
+	return router, provider, repo
+}
+
+func TestOIDCLogin(t *testing.T) {
+	router, provider, repo := newOIDCRouter(t)
+
+	rejected := []struct {
+		name   string
+		claims func(claims jwt.MapClaims)
+		tamper func(callback *url.URL)
+	}{
+		{
+			name: "state mismatch",
+			tamper: func(callback *url.URL) {
+				query := callback.Query()
+				query.Set("state", "forged")
+				callback.RawQuery = query.Encode()
+			},
+		},
+		{
+			name:   "nonce mismatch",
+			claims: func(claims jwt.MapClaims) { claims["nonce"] = "replayed" },
+		},
+		{
+			name:   "audience mismatch",
+			claims: func(claims jwt.MapClaims) { claims["aud"] = "another-client" },
+		},
+	}
+
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.SetClaims(tt.claims)
+			defer provider.SetClaims(nil)
+
+			w := oidcLogin(t, router, tt.tamper)
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("callback = %d %s, want 401", w.Code, w.Body)
+			}
+		})
+	}
+
+	_, err := repo.GetUserByEmail(context.Background(), "ann@example.com")
+	if err == nil {
+		t.Fatal("a rejected sign in provisioned a user")
+	}
+
+	w := oidcLogin(t, router, nil)
+	if w.Code != http.StatusOK || w.Header().Get("Authorization") == "" {
+		t.Fatalf("callback = %d %s, want 200 with a token", w.Code, w.Body)
+	}
+
+	user, err := repo.GetUserByEmail(context.Background(), "ann@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %s", err)
+	}
+
+	if user.HasPassword() || user.DisplayName != "Ann" {
+		t.Fatalf("provisioned user = %+v, want Ann without a password", user)
+	}
+
+	w = oidcLogin(t, router, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("second sign in = %d %s, want 200", w.Code, w.Body)
+	}
+
+	accountSvc := phonebook.NewAccountService(repo, nil, phonebook.DefaultEmailChangeTTL)
+
+	err = accountSvc.DeleteAccount(context.Background(), user.ID, "", "")
+	if err == nil {
+		t.Fatal("DeleteAccount without a password went ahead unconfirmed")
+	}
+
+	err = accountSvc.DeleteAccount(context.Background(), user.ID, "", "Ann@example.com")
+	if err != nil {
+		t.Fatalf("DeleteAccount confirmed with the email address: %s", err)
+	}
+}
+
+func TestOIDCCookie(t *testing.T) {
+	router, _, _ := newOIDCRouter(t)
+
+	cookie := func(header map[string]string) *http.Cookie {
+		t.Helper()
+
+		w := serveHeader(router, "GET", "/login/oidc", "", header)
+		if w.Code != http.StatusFound || len(w.Result().Cookies()) != 1 {
+			t.Fatalf("GET /login/oidc = %d %s, want 302 with the login state", w.Code, w.Body)
+		}
+
+		return w.Result().Cookies()[0]
+	}
+
+	got := cookie(nil)
+	if got.Path != "/login/oidc/callback" || !got.HttpOnly || got.Secure {
+		t.Fatalf("cookie = %+v, want HttpOnly, not Secure and scoped to the callback", got)
+	}
+
+	forwarded := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-For": "203.0.113.7"}
+
+	got = cookie(forwarded)
+	if got.Secure {
+		t.Fatal("cookie is Secure on the word of an untrusted X-Forwarded-Proto")
+	}
+
+	err := router.SetTrustedProxies([]string{"192.0.2.0/24"})
+	if err != nil {
+		t.Fatalf("SetTrustedProxies: %s", err)
+	}
+
+	got = cookie(forwarded)
+	if !got.Secure {
+		t.Fatal("cookie is not Secure behind a trusted TLS terminating proxy")
+	}
+}
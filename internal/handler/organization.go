@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"template/internal/phonebook"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationJSON struct {
+	ID   int    `json:"id"`
+	Name string `json:"name" binding:"required"`
+}
+
+type MemberJSON struct {
+	OrganizationID int       `json:"organization_id"`
+	UserID         int       `json:"user_id"`
+	Email          string    `json:"email" binding:"required"`
+	Role           string    `json:"role" binding:"required,oneof=admin member"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type OrganizationService interface {
+	NewOrganization(ctx context.Context, userID int, organization *phonebook.Organization) error
+	GetMembershipsByUserID(ctx context.Context, userID int) ([]*phonebook.Membership, error)
+	SwitchOrganization(ctx context.Context, userID int, organizationID int) (string, error)
+	Members(ctx context.Context, userID int, organizationID int) ([]*phonebook.Membership, error)
+	InviteMember(ctx context.Context, userID int, organizationID int, email string, role phonebook.Role) (*phonebook.Membership, error)
+	RemoveMember(ctx context.Context, userID int, organizationID int, memberID int) error
+}
+
+type OrganizationHandler struct {
+	organizationSvc OrganizationService
+}
+
+func NewOrganizationHandler(organizationSvc OrganizationService) OrganizationHandler {
+	return OrganizationHandler{organizationSvc}
+}
+
+func newMemberJSON(membership *phonebook.Membership) MemberJSON {
+	return MemberJSON{
+		OrganizationID: membership.Organization.ID,
+		UserID:         membership.User.ID,
+		Email:          membership.User.Email,
+		Role:           string(membership.Role),
+		CreatedAt:      membership.CreatedAt,
+	}
+}
+
+func (h *OrganizationHandler) NewOrganization(ctx *gin.Context) {
+	var input OrganizationJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	organization := &phonebook.Organization{Name: input.Name}
+
+	err := h.organizationSvc.NewOrganization(ctx, userID, organization)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusCreated,
+		gin.H{"message": "success", "data": OrganizationJSON{
+			ID:   organization.ID,
+			Name: organization.Name,
+		}},
+	)
+}
+
+func (h *OrganizationHandler) Organizations(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	memberships, err := h.organizationSvc.GetMembershipsByUserID(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	organizationsResponse := make([]gin.H, 0)
+	for _, membership := range memberships {
+		organizationsResponse = append(organizationsResponse, gin.H{
+			"id":      membership.Organization.ID,
+			"name":    membership.Organization.Name,
+			"role":    membership.Role,
+			"current": membership.Organization.ID == ctx.GetInt("tenant_id"),
+		})
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": organizationsResponse},
+	)
+}
+
+func (h *OrganizationHandler) SwitchOrganization(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	organizationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	token, err := h.organizationSvc.SwitchOrganization(ctx, userID, organizationID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Header("Authorization", "Bearer "+token)
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "access_token": token},
+	)
+}
+
+func (h *OrganizationHandler) Members(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	organizationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	memberships, err := h.organizationSvc.Members(ctx, userID, organizationID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	membersResponse := make([]MemberJSON, 0)
+	for _, membership := range memberships {
+		membersResponse = append(membersResponse, newMemberJSON(membership))
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": membersResponse},
+	)
+}
+
+func (h *OrganizationHandler) InviteMember(ctx *gin.Context) {
+	var input MemberJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	organizationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	membership, err := h.organizationSvc.InviteMember(ctx, userID, organizationID, input.Email, phonebook.Role(input.Role))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusCreated,
+		gin.H{"message": "success", "data": newMemberJSON(membership)},
+	)
+}
+
+func (h *OrganizationHandler) RemoveMember(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	organizationID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	memberID, err := strconv.Atoi(ctx.Param("user_id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = h.organizationSvc.RemoveMember(ctx, userID, organizationID, memberID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
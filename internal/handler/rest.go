@@ -1,38 +1,69 @@
 package handler
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"template/internal/common"
 	"template/internal/phonebook"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type UserJSON struct {
-	Email    string `json:"email" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Email      string `json:"email" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"`
 }
 
 type AddressJSON struct {
-	ID          int    `json:"id"`
-	UserID      int    `json:"user_id"`
-	Name        string `json:"name" binding:"required"`
-	PhoneNumber string `json:"phone_number" binding:"required"`
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Name        string     `json:"name" binding:"required"`
+	PhoneNumber string     `json:"phone_number" binding:"required"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+type AddressPatchJSON struct {
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+type AddressVersionJSON struct {
+	Version     int                         `json:"version"`
+	ActorID     *int                        `json:"actor_id"`
+	Action      string                      `json:"action"`
+	Changes     map[string]phonebook.Change `json:"changes"`
+	Name        string                      `json:"name"`
+	PhoneNumber string                      `json:"phone_number"`
+	CreatedAt   time.Time                   `json:"created_at"`
 }
 
 type UserService interface {
-	Register(context.Context, *phonebook.User) (string, error)
-	Login(context.Context, *phonebook.User) (string, error)
+	Register(ctx context.Context, user *phonebook.User, device string) (string, error)
+	Login(ctx context.Context, user *phonebook.User, device string) (string, error)
 }
 
 type AddressService interface {
 	NewAddress(ctx context.Context, userID int, address *phonebook.Address) error
-	Addresses(ctx context.Context) ([]*phonebook.Address, error)
+	Addresses(ctx context.Context, userID int) ([]*phonebook.Address, error)
 	GetAddressesByUserID(ctx context.Context, userID int) ([]*phonebook.Address, error)
-	GetAddressByID(ctx context.Context, ID int) (*phonebook.Address, error)
+	GetAddressesByTag(ctx context.Context, userID int, tag string) ([]*phonebook.Address, error)
+	GetAddressByID(ctx context.Context, userID int, ID int) (*phonebook.Address, error)
+	SearchAddresses(ctx context.Context, userID int, query string, limit int) ([]*phonebook.Address, error)
 	UpdateAddress(ctx context.Context, userID int, addressID int, newAddress *phonebook.Address) error
-	DeleteAddress(ctx context.Context, userID int, addressID int) error
+	DeleteAddress(ctx context.Context, userID int, addressID int, version int) error
+	Trash(ctx context.Context, userID int) ([]*phonebook.Address, error)
+	RestoreAddress(ctx context.Context, userID int, addressID int) error
+	History(ctx context.Context, userID int, addressID int) ([]*phonebook.AddressVersion, error)
+	RevertAddress(ctx context.Context, userID int, addressID int, version int) error
+	ImportCSV(ctx context.Context, userID int, r io.Reader, mapping phonebook.ColumnMapping, dryRun bool) (*phonebook.ImportResult, error)
+	ExportCSV(ctx context.Context, userID int, w io.Writer) error
+	Batch(ctx context.Context, userID int, ops []*phonebook.BatchOperation, atomic bool) ([]*phonebook.BatchResult, error)
 }
 
 type RESTHandler struct {
@@ -56,7 +87,7 @@ func (h *RESTHandler) Register(ctx *gin.Context) {
 		Password: input.Password,
 	}
 
-	token, err := h.userSvc.Register(ctx, user)
+	token, err := h.userSvc.Register(ctx, user, input.DeviceName)
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -81,7 +112,7 @@ func (h *RESTHandler) Login(ctx *gin.Context) {
 		Password: input.Password,
 	}
 
-	token, err := h.userSvc.Login(ctx, user)
+	token, err := h.userSvc.Login(ctx, user, input.DeviceName)
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -121,7 +152,14 @@ func (h *RESTHandler) NewAddress(ctx *gin.Context) {
 }
 
 func (h *RESTHandler) Addresses(ctx *gin.Context) {
-	addresses, err := h.addressSvc.Addresses(ctx)
+	var addresses []*phonebook.Address
+	var err error
+
+	if tag := ctx.Query("tag"); tag != "" {
+		addresses, err = h.addressSvc.GetAddressesByTag(ctx, ctx.GetInt("user_id"), tag)
+	} else {
+		addresses, err = h.addressSvc.Addresses(ctx, ctx.GetInt("user_id"))
+	}
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -146,7 +184,45 @@ func (h *RESTHandler) Addresses(ctx *gin.Context) {
 func (h *RESTHandler) GetAddressesByUserID(ctx *gin.Context) {
 	userID := ctx.GetInt("user_id")
 
-	addresses, err := h.addressSvc.GetAddressesByUserID(ctx, userID)
+	var addresses []*phonebook.Address
+	var err error
+
+	if tag := ctx.Query("tag"); tag != "" {
+		addresses, err = h.addressSvc.GetAddressesByTag(ctx, userID, tag)
+	} else {
+		addresses, err = h.addressSvc.GetAddressesByUserID(ctx, userID)
+	}
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	addressesResponse := make([]AddressJSON, 0)
+	for _, address := range addresses {
+		addressesResponse = append(addressesResponse, AddressJSON{
+			ID:          address.ID,
+			UserID:      address.User.ID,
+			Name:        address.Name,
+			PhoneNumber: address.PhoneNumber,
+		})
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": addressesResponse},
+	)
+}
+
+func (h *RESTHandler) SearchAddresses(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "0"))
+	if err != nil {
+		ctx.Error(common.InvariantError{Message: "limit must be a number"})
+		return
+	}
+
+	addresses, err := h.addressSvc.SearchAddresses(ctx, userID, ctx.Query("q"), limit)
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -169,18 +245,26 @@ func (h *RESTHandler) GetAddressesByUserID(ctx *gin.Context) {
 }
 
 func (h *RESTHandler) GetAddressByID(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
 	addressID, err := strconv.Atoi(ctx.Param("id"))
 	if err != nil {
 		ctx.Error(err)
 		return
 	}
 
-	address, err := h.addressSvc.GetAddressByID(ctx, addressID)
+	address, err := h.addressSvc.GetAddressByID(ctx, userID, addressID)
 	if err != nil {
 		ctx.Error(err)
 		return
 	}
 
+	ctx.Header("ETag", etag(address.Version))
+	if ifNoneMatch(ctx, address.Version) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
 	ctx.JSON(
 		http.StatusOK,
 		gin.H{"message": "success", "data": AddressJSON{
@@ -199,9 +283,60 @@ func (h *RESTHandler) UpdateAddress(ctx *gin.Context) {
 		return
 	}
 
+	userID := ctx.GetInt("user_id")
+
+	addressID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	version, err := h.expectedVersion(ctx, addressID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
 	address := &phonebook.Address{
 		Name:        input.Name,
 		PhoneNumber: input.PhoneNumber,
+		Version:     version,
+	}
+
+	err = h.addressSvc.UpdateAddress(ctx, userID, addressID, address)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if address.Version > 0 {
+		ctx.Header("ETag", etag(address.Version))
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+func (h *RESTHandler) PatchAddress(ctx *gin.Context) {
+	var apply func(doc []byte, patch []byte) ([]byte, error)
+	switch ctx.ContentType() {
+	case common.MergePatchContentType:
+		apply = common.MergePatch
+	case common.JSONPatchContentType:
+		apply = common.JSONPatch
+	default:
+		// Plain application/json is refused rather than guessed at: a JSON
+		// Patch array merged as a document would replace the address.
+		ctx.Error(common.UnsupportedMediaTypeError{Message: "send " + common.MergePatchContentType + " or " + common.JSONPatchContentType})
+		return
+	}
+
+	patch, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.Error(err)
+		return
 	}
 
 	userID := ctx.GetInt("user_id")
@@ -212,18 +347,87 @@ func (h *RESTHandler) UpdateAddress(ctx *gin.Context) {
 		return
 	}
 
-	err = h.addressSvc.UpdateAddress(ctx, userID, addressID, address)
+	address, err := h.addressSvc.GetAddressByID(ctx, userID, addressID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	doc, err := json.Marshal(AddressPatchJSON{
+		Name:        address.Name,
+		PhoneNumber: address.PhoneNumber,
+	})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	doc, err = apply(doc, patch)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	var input AddressPatchJSON
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		ctx.Error(common.UnprocessableEntityError{Message: "patched address is not a valid address"})
+		return
+	}
+
+	// Without If-Match the write is still conditional on the version the patch
+	// was applied to, so a concurrent update cannot be silently overwritten.
+	version := address.Version
+	if versions := ifMatchVersions(ctx); versions != nil {
+		version = ifMatchVersion(versions, address.Version)
+	}
+
+	patched := &phonebook.Address{
+		Name:        input.Name,
+		PhoneNumber: input.PhoneNumber,
+		Version:     version,
+	}
+
+	err = h.addressSvc.UpdateAddress(ctx, userID, addressID, patched)
 	if err != nil {
 		ctx.Error(err)
 		return
 	}
 
+	ctx.Header("ETag", etag(patched.Version))
 	ctx.JSON(
 		http.StatusOK,
-		gin.H{"message": "success"},
+		gin.H{"message": "success", "data": AddressJSON{
+			ID:          addressID,
+			UserID:      address.User.ID,
+			Name:        patched.Name,
+			PhoneNumber: patched.PhoneNumber,
+		}},
 	)
 }
 
+// expectedVersion resolves If-Match for a write to addressID. A single version
+// is passed on as is and checked when the write applies; several are first
+// matched against the current version, read from the primary.
+func (h *RESTHandler) expectedVersion(ctx *gin.Context, addressID int) (int, error) {
+	versions := ifMatchVersions(ctx)
+	if len(versions) == 1 {
+		return versions[0], nil
+	}
+
+	if len(versions) == 0 {
+		return ifMatchVersion(versions, 0), nil
+	}
+
+	address, err := h.addressSvc.GetAddressByID(common.WithPrimary(ctx), ctx.GetInt("user_id"), addressID)
+	if err != nil {
+		return 0, err
+	}
+
+	return ifMatchVersion(versions, address.Version), nil
+}
+
 func (h *RESTHandler) DeleteAddress(ctx *gin.Context) {
 	userID := ctx.GetInt("user_id")
 
@@ -233,7 +437,60 @@ func (h *RESTHandler) DeleteAddress(ctx *gin.Context) {
 		return
 	}
 
-	err = h.addressSvc.DeleteAddress(ctx, userID, addressID)
+	version, err := h.expectedVersion(ctx, addressID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = h.addressSvc.DeleteAddress(ctx, userID, addressID, version)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+func (h *RESTHandler) Trash(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	addresses, err := h.addressSvc.Trash(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	addressesResponse := make([]AddressJSON, 0)
+	for _, address := range addresses {
+		addressesResponse = append(addressesResponse, AddressJSON{
+			ID:          address.ID,
+			UserID:      address.User.ID,
+			Name:        address.Name,
+			PhoneNumber: address.PhoneNumber,
+			DeletedAt:   address.DeletedAt,
+		})
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": addressesResponse},
+	)
+}
+
+func (h *RESTHandler) RestoreAddress(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	addressID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = h.addressSvc.RestoreAddress(ctx, userID, addressID)
 	if err != nil {
 		ctx.Error(err)
 		return
@@ -244,3 +501,127 @@ func (h *RESTHandler) DeleteAddress(ctx *gin.Context) {
 		gin.H{"message": "success"},
 	)
 }
+
+func (h *RESTHandler) History(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	addressID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	versions, err := h.addressSvc.History(ctx, userID, addressID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	versionsResponse := make([]AddressVersionJSON, 0)
+	for _, version := range versions {
+		var actorID *int
+		if version.Actor != nil {
+			actorID = &version.Actor.ID
+		}
+
+		versionsResponse = append(versionsResponse, AddressVersionJSON{
+			Version:     version.Version,
+			ActorID:     actorID,
+			Action:      string(version.Action),
+			Changes:     version.Changes,
+			Name:        version.Name,
+			PhoneNumber: version.PhoneNumber,
+			CreatedAt:   version.CreatedAt,
+		})
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": versionsResponse},
+	)
+}
+
+func (h *RESTHandler) RevertAddress(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	addressID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	version, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = h.addressSvc.RevertAddress(ctx, userID, addressID, version)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+func (h *RESTHandler) ImportAddresses(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	dryRun, err := strconv.ParseBool(ctx.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		ctx.Error(common.InvariantError{Message: "dry_run must be a boolean"})
+		return
+	}
+
+	var body io.Reader = ctx.Request.Body
+	if ctx.ContentType() == "multipart/form-data" {
+		header, err := ctx.FormFile("file")
+		if err != nil {
+			ctx.Error(common.InvariantError{Message: "file is required"})
+			return
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		defer file.Close()
+
+		body = file
+	}
+
+	result, err := h.addressSvc.ImportCSV(ctx, userID, body, ctx.QueryMap("mapping"), dryRun)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+
+	ctx.JSON(
+		status,
+		gin.H{"message": "success", "data": result},
+	)
+}
+
+func (h *RESTHandler) ExportAddresses(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	var buf bytes.Buffer
+	err := h.addressSvc.ExportCSV(ctx, userID, &buf)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", `attachment; filename="addresses.csv"`)
+	ctx.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
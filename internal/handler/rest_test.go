@@ -0,0 +1,318 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"template/internal/api"
+	"template/internal/common"
+	"template/internal/db"
+	"template/internal/handler"
+	"template/internal/phonebook"
+	"template/internal/repository"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	common.SetLogger(common.NewLogrusLogger())
+}
+
+// newRepository returns a SQLite repository holding user 1, alice with the
+// password "password", and organization 1, whom Authentication signs every
+// request in as in test mode.
+func newRepository(t *testing.T) *repository.SQLiteRepository {
+	t.Helper()
+
+	conn, err := db.ConnectSQLite(filepath.Join(t.TempDir(), "phonebook.db"))
+	if err != nil {
+		t.Fatalf("ConnectSQLite: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	repo := repository.NewSQLiteRepository(conn)
+	ctx := context.Background()
+
+	password, err := common.BcryptHash("password")
+	if err != nil {
+		t.Fatalf("BcryptHash: %s", err)
+	}
+
+	userID, err := repo.NewUser(ctx, &phonebook.User{Email: "alice@example.com", Password: password})
+	if err != nil {
+		t.Fatalf("NewUser: %s", err)
+	}
+
+	err = repo.NewOrganization(ctx, &phonebook.Organization{Name: "alice"}, &phonebook.User{ID: userID})
+	if err != nil {
+		t.Fatalf("NewOrganization: %s", err)
+	}
+
+	return repo
+}
+
+func newRESTRouter(t *testing.T) (*gin.Engine, *repository.SQLiteRepository) {
+	t.Helper()
+
+	repo := newRepository(t)
+	h := handler.NewRESTHandler(phonebook.NewUserService(repo), phonebook.NewAddressService(repo, phonebook.DefaultMaxBatchSize))
+	auth := api.Authentication(phonebook.NewSessionService(repo))
+
+	router := api.Setup(
+		api.Route{Method: "POST", Path: "/addresses", Handler: []gin.HandlerFunc{auth, h.NewAddress}},
+		api.Route{Method: "PUT", Path: "/addresses/:id", Handler: []gin.HandlerFunc{auth, h.UpdateAddress}},
+		api.Route{Method: "PATCH", Path: "/addresses/:id", Handler: []gin.HandlerFunc{auth, h.PatchAddress}},
+		api.Route{Method: "DELETE", Path: "/addresses/:id", Handler: []gin.HandlerFunc{auth, h.DeleteAddress}},
+	)
+
+	return router, repo
+}
+
+func serve(router *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+	return serveHeader(router, method, path, body, nil)
+}
+
+// serveHeader sends a JSON request with header set on top.
+func serveHeader(router *gin.Engine, method string, path string, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestAddressValidation(t *testing.T) {
+	router, repo := newRESTRouter(t)
+
+	address := &phonebook.Address{User: &phonebook.User{ID: 1}, Name: "Carol", PhoneNumber: "+1 555 0100"}
+	err := repo.NewAddress(common.WithTenantID(context.Background(), 1), address)
+	if err != nil {
+		t.Fatalf("NewAddress: %s", err)
+	}
+
+	path := "/addresses/" + strconv.Itoa(address.ID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header map[string]string
+	}{
+		{"create", "POST", "/addresses", `{"name": "Mallory", "phone_number": "abc"}`, nil},
+		{"create blank name", "POST", "/addresses", `{"name": " ", "phone_number": "1"}`, nil},
+		{"update", "PUT", path, `{"name": "Mallory", "phone_number": "abc"}`, nil},
+		{"patch", "PATCH", path, `{"phone_number": "abc"}`, map[string]string{"Content-Type": common.MergePatchContentType}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveHeader(router, tt.method, tt.path, tt.body, tt.header)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("%s %s = %d %s, want 400", tt.method, tt.path, w.Code, w.Body)
+			}
+		})
+	}
+
+	got, err := repo.GetAddressByID(common.WithTenantID(context.Background(), 1), address.ID)
+	if err != nil {
+		t.Fatalf("GetAddressByID: %s", err)
+	}
+
+	if got.PhoneNumber != address.PhoneNumber {
+		t.Fatalf("phone number = %q after rejected writes, want %q", got.PhoneNumber, address.PhoneNumber)
+	}
+}
+
+func TestAddressNotFound(t *testing.T) {
+	router, repo := newRESTRouter(t)
+
+	address := &phonebook.Address{User: &phonebook.User{ID: 1}, Name: "Carol", PhoneNumber: "+1 555 0100"}
+	err := repo.NewAddress(common.WithTenantID(context.Background(), 1), address)
+	if err != nil {
+		t.Fatalf("NewAddress: %s", err)
+	}
+
+	path := "/addresses/" + strconv.Itoa(address.ID)
+
+	w := serve(router, "DELETE", path, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE %s = %d %s, want 200", path, w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"update missing", "PUT", "/addresses/999", `{"name": "Mallory", "phone_number": "1"}`},
+		{"delete missing", "DELETE", "/addresses/999", ""},
+		{"update trashed", "PUT", path, `{"name": "Mallory", "phone_number": "1"}`},
+		{"delete trashed", "DELETE", path, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, tt.method, tt.path, tt.body)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("%s %s = %d %s, want 404", tt.method, tt.path, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	router, repo := newRESTRouter(t)
+
+	address := &phonebook.Address{User: &phonebook.User{ID: 1}, Name: "Carol", PhoneNumber: "+1 555 0100"}
+	err := repo.NewAddress(common.WithTenantID(context.Background(), 1), address)
+	if err != nil {
+		t.Fatalf("NewAddress: %s", err)
+	}
+
+	path := "/addresses/" + strconv.Itoa(address.ID)
+
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		ifMatch string
+		code    int
+		etag    string
+	}{
+		{"any of several", "PUT", `{"name": "Carol", "phone_number": "1"}`, `"7", "1"`, http.StatusOK, `"2"`},
+		{"none of several", "PUT", `{"name": "Carol", "phone_number": "2"}`, `"7", "8"`, http.StatusPreconditionFailed, ""},
+		{"weak tag", "PUT", `{"name": "Carol", "phone_number": "2"}`, `W/"2"`, http.StatusPreconditionFailed, ""},
+		{"weak and strong", "PUT", `{"name": "Carol", "phone_number": "2"}`, `W/"2", "2"`, http.StatusOK, `"3"`},
+		{"weak patch", "PATCH", `{"name": "Dave"}`, `W/"3"`, http.StatusPreconditionFailed, ""},
+		{"patch any of several", "PATCH", `{"name": "Dave"}`, `"2", "3"`, http.StatusOK, `"4"`},
+		{"weak delete", "DELETE", "", `W/"4"`, http.StatusPreconditionFailed, ""},
+		{"delete any of several", "DELETE", "", `"1", "4"`, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{"If-Match": tt.ifMatch}
+			if tt.method == "PATCH" {
+				header["Content-Type"] = common.MergePatchContentType
+			}
+
+			w := serveHeader(router, tt.method, path, tt.body, header)
+			if w.Code != tt.code {
+				t.Fatalf("%s If-Match: %s = %d %s, want %d", tt.method, tt.ifMatch, w.Code, w.Body, tt.code)
+			}
+
+			if tt.etag != "" && w.Header().Get("ETag") != tt.etag {
+				t.Fatalf("ETag = %s, want %s", w.Header().Get("ETag"), tt.etag)
+			}
+		})
+	}
+}
+
+func TestPatchContentType(t *testing.T) {
+	router, repo := newRESTRouter(t)
+
+	address := &phonebook.Address{User: &phonebook.User{ID: 1}, Name: "Carol", PhoneNumber: "+1 555 0100"}
+	err := repo.NewAddress(common.WithTenantID(context.Background(), 1), address)
+	if err != nil {
+		t.Fatalf("NewAddress: %s", err)
+	}
+
+	path := "/addresses/" + strconv.Itoa(address.ID)
+
+	tests := []struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		{"application/json", `[{"op": "replace", "path": "/name", "value": "Dave"}]`, http.StatusUnsupportedMediaType},
+		{"text/plain", `{"name": "Dave"}`, http.StatusUnsupportedMediaType},
+		{common.MergePatchContentType, `{"name": "Dave"}`, http.StatusOK},
+		{common.JSONPatchContentType, `[{"op": "replace", "path": "/name", "value": "Erin"}]`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		w := serveHeader(router, "PATCH", path, tt.body, map[string]string{"Content-Type": tt.contentType})
+		if w.Code != tt.code {
+			t.Fatalf("PATCH as %s = %d %s, want %d", tt.contentType, w.Code, w.Body, tt.code)
+		}
+	}
+}
+
+func TestAddressesByTag(t *testing.T) {
+	router, repo := newRESTRouter(t)
+
+	h := handler.NewRESTHandler(phonebook.NewUserService(repo), phonebook.NewAddressService(repo, phonebook.DefaultMaxBatchSize))
+	auth := api.Authentication(phonebook.NewSessionService(repo))
+	router.Handle("GET", "/addresses", auth, h.Addresses)
+	router.Handle("GET", "/addresses/user", auth, h.GetAddressesByUserID)
+
+	ctx := common.WithActorID(common.WithTenantID(context.Background(), 1), 1)
+
+	addresses := []*phonebook.Address{
+		{User: &phonebook.User{ID: 1}, Name: "Ann", PhoneNumber: "+1 555 0100"},
+		{User: &phonebook.User{ID: 1}, Name: "Bob", PhoneNumber: "+1 555 0101"},
+	}
+	err := repo.NewAddresses(ctx, addresses)
+	if err != nil {
+		t.Fatalf("NewAddresses: %s", err)
+	}
+
+	tag := &phonebook.Tag{User: &phonebook.User{ID: 1}, Name: "friends"}
+	err = repo.NewTag(ctx, tag)
+	if err != nil {
+		t.Fatalf("NewTag: %s", err)
+	}
+
+	err = repo.TagAddresses(ctx, []int{tag.ID}, []int{addresses[1].ID})
+	if err != nil {
+		t.Fatalf("TagAddresses: %s", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/addresses", "[Ann Bob]"},
+		{"/addresses?tag=friends", "[Bob]"},
+		{"/addresses?tag=family", "[]"},
+		{"/addresses/user?tag=friends", "[Bob]"},
+	}
+
+	for _, tt := range tests {
+		w := serve(router, "GET", tt.path, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s, want 200", tt.path, w.Code, w.Body)
+		}
+
+		var res struct {
+			Data []handler.AddressJSON `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &res)
+		if err != nil {
+			t.Fatalf("decode: %s", err)
+		}
+
+		names := make([]string, 0, len(res.Data))
+		for _, address := range res.Data {
+			names = append(names, address.Name)
+		}
+
+		if fmt.Sprint(names) != tt.want {
+			t.Errorf("GET %s = %v, want %s", tt.path, names, tt.want)
+		}
+	}
+}
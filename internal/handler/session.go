@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"template/internal/phonebook"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionJSON struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"device_name"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+type SessionService interface {
+	Sessions(ctx context.Context, userID int) ([]*phonebook.Session, error)
+	RevokeSession(ctx context.Context, userID int, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, userID int) (int, error)
+}
+
+type SessionHandler struct {
+	sessionSvc SessionService
+}
+
+func NewSessionHandler(sessionSvc SessionService) SessionHandler {
+	return SessionHandler{sessionSvc}
+}
+
+func (h *SessionHandler) Sessions(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+	current := ctx.GetString("session_id")
+
+	sessions, err := h.sessionSvc.Sessions(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	res := make([]SessionJSON, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, SessionJSON{
+			ID:         session.ID,
+			DeviceName: session.DeviceName,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == current,
+		})
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": res},
+	)
+}
+
+// RevokeSession signs out one device. Revoking the current session logs
+// the caller out.
+func (h *SessionHandler) RevokeSession(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	err := h.sessionSvc.RevokeSession(ctx, userID, ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+// RevokeOtherSessions signs out every device but the one making the request.
+func (h *SessionHandler) RevokeOtherSessions(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	n, err := h.sessionSvc.RevokeOtherSessions(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": gin.H{"revoked": n}},
+	)
+}
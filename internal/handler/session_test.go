@@ -0,0 +1,159 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"template/internal/api"
+	"template/internal/common"
+	"template/internal/config"
+	"template/internal/handler"
+	"template/internal/phonebook"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newSessionRouter serves the session routes with Authentication checking
+// real tokens, since test mode signs every request in without a session.
+// It returns a bearer token for each of alice's sessions "laptop" and
+// "phone".
+func newSessionRouter(t *testing.T) (*gin.Engine, map[string]string) {
+	t.Helper()
+
+	gin.SetMode(gin.ReleaseMode)
+	t.Cleanup(func() { gin.SetMode(gin.TestMode) })
+
+	secret, issuer := config.JWT_SECRET, config.JWT_ISSUER
+	config.JWT_SECRET, config.JWT_ISSUER = "secret", "test"
+	t.Cleanup(func() { config.JWT_SECRET, config.JWT_ISSUER = secret, issuer })
+
+	repo := newRepository(t)
+	sessionSvc := phonebook.NewSessionService(repo)
+	h := handler.NewSessionHandler(sessionSvc)
+	auth := api.Authentication(sessionSvc)
+
+	router := api.Setup(
+		api.Route{Method: "GET", Path: "/me/sessions", Handler: []gin.HandlerFunc{auth, h.Sessions}},
+		api.Route{Method: "DELETE", Path: "/me/sessions", Handler: []gin.HandlerFunc{auth, h.RevokeOtherSessions}},
+		api.Route{Method: "DELETE", Path: "/me/sessions/:id", Handler: []gin.HandlerFunc{auth, h.RevokeSession}},
+	)
+
+	tokens := make(map[string]string)
+	for _, id := range []string{"laptop", "phone"} {
+		session := &phonebook.Session{ID: id, UserID: 1, DeviceName: id, ExpiresAt: time.Now().Add(time.Hour)}
+		err := repo.NewSession(context.Background(), session)
+		if err != nil {
+			t.Fatalf("NewSession: %s", err)
+		}
+
+		token, err := common.JwtGenerate(1, 1, id)
+		if err != nil {
+			t.Fatalf("JwtGenerate: %s", err)
+		}
+
+		tokens[id] = "Bearer " + token
+	}
+
+	return router, tokens
+}
+
+// sessions lists alice's sessions as seen from the session token signs in
+// with, mapping each session ID to whether it is the current one.
+func sessions(t *testing.T, router *gin.Engine, token string) map[string]bool {
+	t.Helper()
+
+	w := serveHeader(router, "GET", "/me/sessions", "", map[string]string{"Authorization": token})
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /me/sessions = %d %s, want 200", w.Code, w.Body)
+	}
+
+	var res struct {
+		Data []handler.SessionJSON `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &res)
+	if err != nil {
+		t.Fatalf("decode: %s", err)
+	}
+
+	current := make(map[string]bool)
+	for _, session := range res.Data {
+		current[session.ID] = session.Current
+	}
+
+	return current
+}
+
+func TestSessions(t *testing.T) {
+	router, tokens := newSessionRouter(t)
+
+	got := sessions(t, router, tokens["laptop"])
+	if len(got) != 2 || !got["laptop"] || got["phone"] {
+		t.Fatalf("sessions from the laptop = %v, want the laptop current and the phone not", got)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	router, tokens := newSessionRouter(t)
+
+	w := serveHeader(router, "DELETE", "/me/sessions/phone", "", map[string]string{"Authorization": tokens["laptop"]})
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE /me/sessions/phone = %d %s, want 200", w.Code, w.Body)
+	}
+
+	w = serveHeader(router, "GET", "/me/sessions", "", map[string]string{"Authorization": tokens["phone"]})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("GET /me/sessions with a revoked token = %d %s, want 401", w.Code, w.Body)
+	}
+
+	got := sessions(t, router, tokens["laptop"])
+	if len(got) != 1 || !got["laptop"] {
+		t.Fatalf("sessions after revoking the phone = %v, want only the laptop", got)
+	}
+
+	w = serveHeader(router, "DELETE", "/me/sessions/phone", "", map[string]string{"Authorization": tokens["laptop"]})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("DELETE of a revoked session = %d %s, want 404", w.Code, w.Body)
+	}
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	router, tokens := newSessionRouter(t)
+
+	w := serveHeader(router, "DELETE", "/me/sessions", "", map[string]string{"Authorization": tokens["phone"]})
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE /me/sessions = %d %s, want 200", w.Code, w.Body)
+	}
+
+	var res struct {
+		Data struct {
+			Revoked int `json:"revoked"`
+		} `json:"data"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &res)
+	if err != nil || res.Data.Revoked != 1 {
+		t.Fatalf("DELETE /me/sessions = %s, want 1 revoked", w.Body)
+	}
+
+	w = serveHeader(router, "GET", "/me/sessions", "", map[string]string{"Authorization": tokens["laptop"]})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("GET /me/sessions from the laptop = %d %s, want 401", w.Code, w.Body)
+	}
+
+	got := sessions(t, router, tokens["phone"])
+	if len(got) != 1 || !got["phone"] {
+		t.Fatalf("sessions after revoking the others = %v, want only the phone", got)
+	}
+}
+
+func TestAuthenticationWithoutToken(t *testing.T) {
+	router, _ := newSessionRouter(t)
+
+	for _, header := range []map[string]string{nil, {"Authorization": "Bearer forged"}} {
+		w := serveHeader(router, "GET", "/me/sessions", "", header)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("GET /me/sessions with %v = %d %s, want 401", header, w.Code, w.Body)
+		}
+	}
+}
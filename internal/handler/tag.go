@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"template/internal/phonebook"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TagJSON struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Name   string `json:"name" binding:"required"`
+}
+
+type TagAssignmentJSON struct {
+	TagIDs     []int `json:"tag_ids" binding:"required,min=1"`
+	AddressIDs []int `json:"address_ids" binding:"required,min=1"`
+}
+
+type TagService interface {
+	NewTag(ctx context.Context, userID int, tag *phonebook.Tag) error
+	GetTagsByUserID(ctx context.Context, userID int) ([]*phonebook.Tag, error)
+	GetTagByID(ctx context.Context, userID int, tagID int) (*phonebook.Tag, error)
+	UpdateTag(ctx context.Context, userID int, tagID int, newTag *phonebook.Tag) error
+	DeleteTag(ctx context.Context, userID int, tagID int) error
+	TagAddresses(ctx context.Context, userID int, tagIDs []int, addressIDs []int) error
+	UntagAddresses(ctx context.Context, userID int, tagIDs []int, addressIDs []int) error
+}
+
+type TagHandler struct {
+	tagSvc TagService
+}
+
+func NewTagHandler(tagSvc TagService) TagHandler {
+	return TagHandler{tagSvc}
+}
+
+func (h *TagHandler) NewTag(ctx *gin.Context) {
+	var input TagJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	tag := &phonebook.Tag{Name: input.Name}
+
+	err := h.tagSvc.NewTag(ctx, userID, tag)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusCreated,
+		gin.H{"message": "success", "data": TagJSON{
+			ID:     tag.ID,
+			UserID: tag.User.ID,
+			Name:   tag.Name,
+		}},
+	)
+}
+
+func (h *TagHandler) Tags(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	tags, err := h.tagSvc.GetTagsByUserID(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	tagsResponse := make([]TagJSON, 0)
+	for _, tag := range tags {
+		tagsResponse = append(tagsResponse, TagJSON{
+			ID:     tag.ID,
+			UserID: tag.User.ID,
+			Name:   tag.Name,
+		})
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": tagsResponse},
+	)
+}
+
+func (h *TagHandler) GetTagByID(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	tagID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	tag, err := h.tagSvc.GetTagByID(ctx, userID, tagID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success", "data": TagJSON{
+			ID:     tag.ID,
+			UserID: tag.User.ID,
+			Name:   tag.Name,
+		}},
+	)
+}
+
+func (h *TagHandler) UpdateTag(ctx *gin.Context) {
+	var input TagJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	tagID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = h.tagSvc.UpdateTag(ctx, userID, tagID, &phonebook.Tag{Name: input.Name})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+func (h *TagHandler) DeleteTag(ctx *gin.Context) {
+	userID := ctx.GetInt("user_id")
+
+	tagID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = h.tagSvc.DeleteTag(ctx, userID, tagID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+func (h *TagHandler) TagAddress(ctx *gin.Context) {
+	h.assignOne(ctx, h.tagSvc.TagAddresses)
+}
+
+func (h *TagHandler) UntagAddress(ctx *gin.Context) {
+	h.assignOne(ctx, h.tagSvc.UntagAddresses)
+}
+
+func (h *TagHandler) BulkTag(ctx *gin.Context) {
+	h.assignMany(ctx, h.tagSvc.TagAddresses)
+}
+
+func (h *TagHandler) BulkUntag(ctx *gin.Context) {
+	h.assignMany(ctx, h.tagSvc.UntagAddresses)
+}
+
+type assignFunc func(ctx context.Context, userID int, tagIDs []int, addressIDs []int) error
+
+func (h *TagHandler) assignOne(ctx *gin.Context, assign assignFunc) {
+	userID := ctx.GetInt("user_id")
+
+	addressID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	tagID, err := strconv.Atoi(ctx.Param("tag_id"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	err = assign(ctx, userID, []int{tagID}, []int{addressID})
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
+
+func (h *TagHandler) assignMany(ctx *gin.Context, assign assignFunc) {
+	var input TagAssignmentJSON
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	userID := ctx.GetInt("user_id")
+
+	err := assign(ctx, userID, input.TagIDs, input.AddressIDs)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(
+		http.StatusOK,
+		gin.H{"message": "success"},
+	)
+}
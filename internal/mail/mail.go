@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"template/internal/common"
+	"time"
+)
+
+// SMTP sends plain text email through a relay.
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP sends through the relay at addr (host:port) from the given
+// sender. Without a username the relay is used unauthenticated.
+func NewSMTP(addr string, from string, username string, password string) *SMTP {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTP{addr, from, auth}
+}
+
+func (m *SMTP) Send(ctx context.Context, to string, subject string, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("mail: header contains a line break")
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		strings.ReplaceAll(body, "\n", "\r\n"),
+	}, "\r\n")
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg))
+}
+
+// Log writes email to the application log instead of sending it, for
+// development setups without a relay.
+type Log struct{}
+
+func (Log) Send(ctx context.Context, to string, subject string, body string) error {
+	common.Log.Infof("mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
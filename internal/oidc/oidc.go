@@ -0,0 +1,278 @@
+// Package oidc is an OpenID Connect relying party for the authorization code
+// flow with PKCE. It discovers the provider from its issuer URL and verifies
+// ID tokens against the provider's published keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"template/internal/common"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the browser back to, the
+	// callback route of this server.
+	RedirectURL string
+	Scopes      []string
+	// StateKey signs the login state kept in the browser between the
+	// redirect to the provider and the callback.
+	StateKey []byte
+	Client   *http.Client
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Identity is what a verified ID token says about the signed in user.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// RelyingParty signs users in with one provider. Discovery happens on first
+// use and is retried until it succeeds, so the server can start while the
+// provider is unreachable.
+type RelyingParty struct {
+	config Config
+	client *http.Client
+
+	mu   sync.Mutex
+	meta *metadata
+	keys *keySet
+}
+
+func New(config Config) *RelyingParty {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &RelyingParty{config: config, client: client}
+}
+
+func (rp *RelyingParty) discover(ctx context.Context) (*metadata, *keySet, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.meta != nil {
+		return rp.meta, rp.keys, nil
+	}
+
+	var meta metadata
+	err := rp.getJSON(ctx, strings.TrimSuffix(rp.config.Issuer, "/")+"/.well-known/openid-configuration", &meta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+
+	if meta.Issuer != rp.config.Issuer {
+		return nil, nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", meta.Issuer, rp.config.Issuer)
+	}
+
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, nil, fmt.Errorf("oidc discovery: incomplete provider metadata")
+	}
+
+	rp.meta = &meta
+	rp.keys = newKeySet(meta.JWKSURI, rp.getJSON)
+
+	return rp.meta, rp.keys, nil
+}
+
+// Begin starts a sign in. The browser is sent to the returned URL and the
+// state has to come back with the callback, sealed with SealState.
+func (rp *RelyingParty) Begin(ctx context.Context, device string) (string, *LoginState, error) {
+	meta, _, err := rp.discover(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	state := &LoginState{
+		State:     randomString(),
+		Nonce:     randomString(),
+		Verifier:  randomString(),
+		Device:    device,
+		ExpiresAt: time.Now().Add(LoginTimeout).Unix(),
+	}
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", rp.config.ClientID)
+	query.Set("redirect_uri", rp.config.RedirectURL)
+	query.Set("scope", strings.Join(rp.config.Scopes, " "))
+	query.Set("state", state.State)
+	query.Set("nonce", state.Nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return meta.AuthorizationEndpoint + sep + query.Encode(), state, nil
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems the authorization code of a callback for an ID token and
+// returns the identity it asserts. Codes, tokens and nonces that do not
+// check out are reported as common.AuthenticationError.
+func (rp *RelyingParty) Exchange(ctx context.Context, code string, state *LoginState) (*Identity, error) {
+	meta, keys, err := rp.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", rp.config.RedirectURL)
+	form.Set("code_verifier", state.Verifier)
+	if rp.config.ClientSecret == "" {
+		form.Set("client_id", rp.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if rp.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(rp.config.ClientID), url.QueryEscape(rp.config.ClientSecret))
+	}
+
+	res, err := rp.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token: %w", err)
+	}
+	defer res.Body.Close()
+
+	var token tokenResponse
+	err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&token)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, common.AuthenticationError{Message: fmt.Sprintf("identity provider rejected the code: %s %s", token.Error, token.ErrorDescription)}
+	}
+
+	if token.IDToken == "" {
+		return nil, common.AuthenticationError{Message: "identity provider returned no ID token"}
+	}
+
+	return rp.verify(ctx, meta, keys, token.IDToken, state.Nonce)
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string `json:"nonce"`
+	AuthorizedParty string `json:"azp"`
+	Email           string `json:"email"`
+	EmailVerified   bool   `json:"email_verified"`
+	Name            string `json:"name"`
+}
+
+func (rp *RelyingParty) verify(ctx context.Context, meta *metadata, keys *keySet, raw string, nonce string) (*Identity, error) {
+	claims := &idTokenClaims{}
+
+	_, err := jwt.ParseWithClaims(
+		raw,
+		claims,
+		func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
+			return keys.key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(rp.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, common.AuthenticationError{Message: "invalid ID token"}
+	}
+
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != rp.config.ClientID {
+		return nil, common.AuthenticationError{Message: "ID token issued to another client"}
+	}
+
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, common.AuthenticationError{Message: "ID token nonce mismatch"}
+	}
+
+	if claims.Subject == "" {
+		return nil, common.AuthenticationError{Message: "ID token subject missing"}
+	}
+
+	return &Identity{
+		Issuer:        meta.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+func (rp *RelyingParty) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := rp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
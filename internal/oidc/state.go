@@ -0,0 +1,79 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"template/internal/common"
+	"time"
+)
+
+// LoginTimeout is how long a user has to finish signing in at the provider.
+var LoginTimeout = 10 * time.Minute
+
+// LoginState is what the callback needs to finish a sign in started by
+// Begin. It is kept by the browser, so it is signed but not secret: the
+// PKCE verifier alone is useless without the code, which only the provider
+// hands out.
+type LoginState struct {
+	State     string `json:"state"`
+	Nonce     string `json:"nonce"`
+	Verifier  string `json:"verifier"`
+	Device    string `json:"device"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// SealState encodes state into a value safe to store in a cookie.
+func (rp *RelyingParty) SealState(state *LoginState) (string, error) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(rp.sign(encoded)), nil
+}
+
+// OpenState decodes a value made by SealState, rejecting it if it was
+// tampered with or has expired.
+func (rp *RelyingParty) OpenState(value string) (*LoginState, error) {
+	invalid := common.AuthenticationError{Message: "sign in expired, start again"}
+
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, invalid
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, rp.sign(encoded)) {
+		return nil, invalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var state LoginState
+	err = json.Unmarshal(payload, &state)
+	if err != nil {
+		return nil, invalid
+	}
+
+	if time.Now().Unix() > state.ExpiresAt {
+		return nil, invalid
+	}
+
+	return &state, nil
+}
+
+func (rp *RelyingParty) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, rp.config.StateKey)
+	mac.Write([]byte("oidc-login-state:"))
+	mac.Write([]byte(encoded))
+
+	return mac.Sum(nil)
+}
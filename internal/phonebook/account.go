@@ -0,0 +1,343 @@
+package phonebook
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"template/internal/common"
+	"time"
+)
+
+// DefaultEmailChangeTTL is how long a confirmation token for a new email
+// address stays valid unless the service is given another lifetime.
+const DefaultEmailChangeTTL = 24 * time.Hour
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// Mailer delivers email to users.
+type Mailer interface {
+	Send(ctx context.Context, to string, subject string, body string) error
+}
+
+// EmailChange is a requested but unconfirmed switch of a user's email. Only
+// a hash of the confirmation token is kept.
+type EmailChange struct {
+	UserID    int
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+}
+
+// ProfilePatch holds the profile fields to change; nil fields are kept.
+type ProfilePatch struct {
+	DisplayName *string
+	Locale      *string
+	Timezone    *string
+	AvatarURL   *string
+}
+
+type AccountRepository interface {
+	Transactor
+	AuditRecorder
+	GetUserByID(context.Context, int) (*User, error)
+	UpdateProfile(context.Context, *User) error
+	UpdatePassword(ctx context.Context, userID int, password string) error
+	RevokeOtherSessions(ctx context.Context, userID int, keepID string) (int, error)
+	// NewEmailChange replaces any pending change of the user's email.
+	NewEmailChange(context.Context, *EmailChange) error
+	GetEmailChange(ctx context.Context, userID int) (*EmailChange, error)
+	DeleteEmailChange(ctx context.Context, userID int) error
+	UpdateEmail(ctx context.Context, userID int, email string) error
+	GetMembershipsByUserID(context.Context, int) ([]*Membership, error)
+	GetMembershipsByOrganizationID(context.Context, int) ([]*Membership, error)
+	GetAddressesByUserID(context.Context, int) ([]*Address, error)
+	GetDeletedAddressesByUserID(context.Context, int) ([]*Address, error)
+	GetCollectionsByUserID(context.Context, int) ([]*Collection, error)
+	GetPendingSharesByUserID(context.Context, int) ([]*Share, error)
+	GetTagsByUserID(context.Context, int) ([]*Tag, error)
+	GetTagsByAddressID(context.Context, int) ([]*Tag, error)
+	GetAddressVersions(context.Context, int) ([]*AddressVersion, error)
+	GetSessionsByUserID(context.Context, int) ([]*Session, error)
+	GetIdentitiesByUserID(context.Context, int) ([]*Identity, error)
+	GetAuditEvents(context.Context, *AuditFilter) ([]*AuditEvent, error)
+	// DeleteOrganization removes the organization with its memberships and
+	// every address in it.
+	DeleteOrganization(ctx context.Context, ID int) error
+	// DeleteUser removes the user with their addresses, tags, collections,
+	// shares, memberships, sessions, identities and pending changes, and
+	// detaches them from the history of addresses they edited.
+	DeleteUser(ctx context.Context, ID int) error
+}
+
+var errNoMailer = common.UnavailableError{Message: "email changes are not available"}
+
+type AccountService struct {
+	repo           AccountRepository
+	mailer         Mailer
+	emailChangeTTL time.Duration
+}
+
+// NewAccountService returns an AccountService whose email change tokens stay
+// valid for emailChangeTTL. mailer may be nil, which turns email changes off.
+func NewAccountService(repo AccountRepository, mailer Mailer, emailChangeTTL time.Duration) *AccountService {
+	return &AccountService{repo, mailer, emailChangeTTL}
+}
+
+func (s *AccountService) Profile(ctx context.Context, userID int) (*User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *AccountService) UpdateProfile(ctx context.Context, userID int, patch *ProfilePatch) (*User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*patch.DisplayName)
+	}
+	if patch.Locale != nil {
+		user.Locale = *patch.Locale
+	}
+	if patch.Timezone != nil {
+		user.Timezone = *patch.Timezone
+	}
+	if patch.AvatarURL != nil {
+		user.AvatarURL = *patch.AvatarURL
+	}
+
+	err = validateProfile(user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.UpdateProfile(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func validateProfile(user *User) error {
+	if len(user.DisplayName) > 100 {
+		return common.InvariantError{Message: "display_name is too long"}
+	}
+
+	if user.Locale != "" && !localePattern.MatchString(user.Locale) {
+		return common.InvariantError{Message: "locale must be a language tag such as en or en-US"}
+	}
+
+	if user.Timezone != "" {
+		if _, err := time.LoadLocation(user.Timezone); err != nil {
+			return common.InvariantError{Message: "timezone must be an IANA time zone such as Europe/Berlin"}
+		}
+	}
+
+	if user.AvatarURL != "" {
+		u, err := url.Parse(user.AvatarURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || len(user.AvatarURL) > 2048 {
+			return common.InvariantError{Message: "avatar_url must be an http or https URL"}
+		}
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the user's password and signs out every other
+// session. A user without a password sets their first one without current.
+func (s *AccountService) ChangePassword(ctx context.Context, userID int, current string, password string) error {
+	event := &AuditEvent{ActorID: userID, Action: AuditPasswordChange, Target: fmt.Sprintf("user:%d", userID)}
+
+	err := s.changePassword(ctx, userID, current, password)
+
+	return recordAudit(ctx, s.repo, event, err)
+}
+
+func (s *AccountService) changePassword(ctx context.Context, userID int, current string, password string) error {
+	err := s.checkPassword(ctx, userID, current)
+	if err != nil {
+		return err
+	}
+
+	hash, err := common.BcryptHash(password)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		err := s.repo.UpdatePassword(ctx, userID, hash)
+		if err != nil {
+			return err
+		}
+
+		sessionID, _ := common.SessionID(ctx)
+		_, err = s.repo.RevokeOtherSessions(ctx, userID, sessionID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// RequestEmailChange mails a confirmation token to the new address. The
+// account keeps its current email until ConfirmEmailChange is called with
+// that token. Without a mailer email changes are refused.
+func (s *AccountService) RequestEmailChange(ctx context.Context, userID int, email string, password string) error {
+	if s.mailer == nil {
+		return errNoMailer
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return common.InvariantError{Message: "email is invalid"}
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(user.Email, email) {
+		return common.InvariantError{Message: "email is unchanged"}
+	}
+
+	err = s.checkPassword(ctx, userID, password)
+	if err != nil {
+		return err
+	}
+
+	raw := make([]byte, 32)
+	_, err = rand.Read(raw)
+	if err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+
+	change := &EmailChange{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.emailChangeTTL),
+	}
+
+	err = s.repo.NewEmailChange(ctx, change)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"Confirm this address for your phonebook account with the code below.\n\n%s\n\nThe code expires at %s. If you did not ask for this, ignore this email.\n",
+		token,
+		change.ExpiresAt.UTC().Format(time.RFC1123),
+	)
+
+	return s.mailer.Send(ctx, email, "Confirm your new email address", body)
+}
+
+// ConfirmEmailChange switches the account to the pending email address
+// once the token mailed to it is presented, and notifies the old address.
+func (s *AccountService) ConfirmEmailChange(ctx context.Context, userID int, token string) (*User, error) {
+	if s.mailer == nil {
+		return nil, errNoMailer
+	}
+
+	event := &AuditEvent{ActorID: userID, Action: AuditEmailChange, Target: fmt.Sprintf("user:%d", userID)}
+
+	user, previous, err := s.confirmEmailChange(ctx, userID, token)
+
+	err = recordAudit(ctx, s.repo, event, err)
+	if err != nil {
+		return nil, err
+	}
+
+	body := fmt.Sprintf("The email address of your phonebook account was changed to %s.\n", user.Email)
+	err = s.mailer.Send(ctx, previous, "Your email address was changed", body)
+	if err != nil {
+		common.Log.Errorf("notify %s of email change: %s", previous, err)
+	}
+
+	return user, nil
+}
+
+func (s *AccountService) confirmEmailChange(ctx context.Context, userID int, token string) (*User, string, error) {
+	var user *User
+	var previous string
+
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		change, err := s.repo.GetEmailChange(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(change.TokenHash), []byte(hashToken(token))) != 1 {
+			return common.InvariantError{Message: "invalid confirmation token"}
+		}
+
+		if !change.ExpiresAt.After(time.Now()) {
+			return common.InvariantError{Message: "confirmation token expired"}
+		}
+
+		user, err = s.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		previous = user.Email
+
+		err = s.repo.UpdateEmail(ctx, userID, change.Email)
+		if err != nil {
+			return err
+		}
+		user.Email = change.Email
+
+		return s.repo.DeleteEmailChange(ctx, userID)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, previous, nil
+}
+
+// checkPassword confirms a sensitive change with the user's password. A
+// user without one signed in through their identity provider, which is
+// all the confirmation there is, so the check passes.
+func (s *AccountService) checkPassword(ctx context.Context, userID int, password string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.HasPassword() {
+		return nil
+	}
+
+	ok, err := common.BcryptCompare(user.Password, password)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return common.InvariantError{Message: "incorrect password"}
+	}
+
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,34 +2,100 @@ package phonebook
 
 import (
 	"context"
+	"regexp"
+	"strconv"
+	"strings"
 	"template/internal/common"
+	"time"
 )
 
+var phoneNumberPattern = regexp.MustCompile(`^\+?[0-9 ()\-.]+$`)
+
 type Address struct {
 	ID          int
 	User        *User
+	Collection  *Collection
 	Name        string
 	PhoneNumber string
+	// Version increases with every write and backs optimistic concurrency:
+	// writes given a non-zero Version only apply if it is still current.
+	Version   int
+	DeletedAt *time.Time
 }
 
 type AddressRepository interface {
+	Transactor
+	PermissionRepository
+	AuditRecorder
 	NewAddress(context.Context, *Address) error
+	NewAddresses(context.Context, []*Address) error
 	Addresses(context.Context) ([]*Address, error)
 	GetAddressesByUserID(context.Context, int) ([]*Address, error)
+	// GetVisibleAddresses lists the addresses userID has at least viewer
+	// permission on.
+	GetVisibleAddresses(ctx context.Context, userID int) ([]*Address, error)
+	GetAddressesByTag(ctx context.Context, userID int, tag string) ([]*Address, error)
 	GetAddressByID(context.Context, int) (*Address, error)
+	// LockAddress reads an address, trashed or not, and holds it against
+	// concurrent writes until the surrounding transaction ends.
+	LockAddress(ctx context.Context, ID int, deleted bool) (*Address, error)
+	SearchAddresses(ctx context.Context, userID int, query string, limit int) ([]*Address, error)
 	UpdateAddress(context.Context, int, *Address) error
-	DeleteAddress(context.Context, int) error
+	DeleteAddress(ctx context.Context, ID int, version int) error
+	GetDeletedAddressesByUserID(context.Context, int) ([]*Address, error)
+	GetDeletedAddressByID(context.Context, int) (*Address, error)
+	RestoreAddress(context.Context, int) error
+	// PurgeDeletedAddresses removes addresses trashed before the given time
+	// across every tenant.
+	PurgeDeletedAddresses(ctx context.Context, before time.Time) (int, error)
+	GetAddressVersions(context.Context, int) ([]*AddressVersion, error)
+	GetAddressVersion(ctx context.Context, addressID int, version int) (*AddressVersion, error)
+	RevertAddress(ctx context.Context, ID int, to *AddressVersion) error
 }
 
 type AddressService struct {
-	repo AddressRepository
+	repo         AddressRepository
+	maxBatchSize int
+}
+
+// NewAddressService returns an AddressService that accepts batches of up to
+// maxBatchSize operations.
+func NewAddressService(repo AddressRepository, maxBatchSize int) *AddressService {
+	return &AddressService{repo, maxBatchSize}
+}
+
+// ValidateAddress lists what is wrong with the address, if anything. Every
+// write path goes through NewAddress or UpdateAddress, which reject an
+// invalid address; CSV import calls it directly to report errors per row.
+func ValidateAddress(address *Address) []string {
+	errs := make([]string, 0)
+
+	if strings.TrimSpace(address.Name) == "" {
+		errs = append(errs, "name is required")
+	}
+
+	if strings.TrimSpace(address.PhoneNumber) == "" {
+		errs = append(errs, "phone_number is required")
+	} else if !phoneNumberPattern.MatchString(address.PhoneNumber) {
+		errs = append(errs, "phone_number is invalid")
+	}
+
+	return errs
 }
 
-func NewAddressService(repo AddressRepository) *AddressService {
-	return &AddressService{repo}
+func validateAddress(address *Address) error {
+	if errs := ValidateAddress(address); len(errs) > 0 {
+		return common.InvariantError{Message: strings.Join(errs, ", ")}
+	}
+
+	return nil
 }
 
 func (s *AddressService) NewAddress(ctx context.Context, userID int, address *Address) error {
+	if err := validateAddress(address); err != nil {
+		return err
+	}
+
 	address.User = &User{ID: userID}
 
 	err := s.repo.NewAddress(ctx, address)
@@ -40,8 +106,9 @@ func (s *AddressService) NewAddress(ctx context.Context, userID int, address *Ad
 	return nil
 }
 
-func (s *AddressService) Addresses(ctx context.Context) ([]*Address, error) {
-	addresses, err := s.repo.Addresses(ctx)
+// Addresses lists the addresses of the tenant that userID may view.
+func (s *AddressService) Addresses(ctx context.Context, userID int) ([]*Address, error) {
+	addresses, err := s.repo.GetVisibleAddresses(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -58,51 +125,136 @@ func (s *AddressService) GetAddressesByUserID(ctx context.Context, userID int) (
 	return addresses, nil
 }
 
-func (s *AddressService) GetAddressByID(ctx context.Context, ID int) (*Address, error) {
+func (s *AddressService) GetAddressesByTag(ctx context.Context, userID int, tag string) ([]*Address, error) {
+	addresses, err := s.repo.GetAddressesByTag(ctx, userID, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+func (s *AddressService) GetAddressByID(ctx context.Context, userID int, ID int) (*Address, error) {
 	address, err := s.repo.GetAddressByID(ctx, ID)
 	if err != nil {
 		return nil, err
 	}
 
-	if address == nil {
-		return nil, common.NotFoundError{Message: "not found"}
+	permission, err := AddressPermission(ctx, s.repo, userID, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if !permission.Allows(PermissionViewer) {
+		return nil, common.AuthorizationError{Message: "unauthorized read"}
 	}
 
 	return address, nil
 }
 
 func (s *AddressService) UpdateAddress(ctx context.Context, userID int, addressID int, newAddress *Address) error {
-	address, err := s.repo.GetAddressByID(ctx, addressID)
-	if err != nil {
+	if err := validateAddress(newAddress); err != nil {
 		return err
 	}
 
-	if address.User.ID != userID {
-		return common.AuthorizationError{Message: "unauthorized update"}
-	}
+	return s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		address, err := s.repo.LockAddress(ctx, addressID, false)
+		if err != nil {
+			return err
+		}
+
+		permission, err := AddressPermission(ctx, s.repo, userID, address)
+		if err != nil {
+			return err
+		}
+
+		if !permission.Allows(PermissionEditor) {
+			return common.AuthorizationError{Message: "unauthorized update"}
+		}
+
+		err = s.repo.UpdateAddress(ctx, addressID, newAddress)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
 
-	err = s.repo.UpdateAddress(ctx, addressID, newAddress)
+// DeleteAddress moves the address to the trash. The audit event of a
+// successful delete is written in the same unit of work, so one never
+// commits without the other; a failed delete is recorded afterwards.
+func (s *AddressService) DeleteAddress(ctx context.Context, userID int, addressID int, version int) error {
+	event := &AuditEvent{ActorID: userID, Action: AuditAddressDelete, Target: "address:" + strconv.Itoa(addressID)}
+
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		address, err := s.repo.LockAddress(ctx, addressID, false)
+		if err != nil {
+			return err
+		}
+
+		permission, err := AddressPermission(ctx, s.repo, userID, address)
+		if err != nil {
+			return err
+		}
+
+		if !permission.Allows(PermissionEditor) {
+			return common.AuthorizationError{Message: "unauthorized delete"}
+		}
+
+		err = s.repo.DeleteAddress(ctx, address.ID, version)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, s.repo, event, nil)
+	})
 	if err != nil {
-		return err
+		return recordAudit(ctx, s.repo, event, err)
 	}
 
 	return nil
 }
 
-func (s *AddressService) DeleteAddress(ctx context.Context, userID int, addressID int) error {
-	address, err := s.repo.GetAddressByID(ctx, addressID)
+func (s *AddressService) Trash(ctx context.Context, userID int) ([]*Address, error) {
+	addresses, err := s.repo.GetDeletedAddressesByUserID(ctx, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if address.User.ID != userID {
-		return common.AuthorizationError{Message: "unauthorized delete"}
-	}
+	return addresses, nil
+}
+
+func (s *AddressService) RestoreAddress(ctx context.Context, userID int, addressID int) error {
+	return s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		address, err := s.repo.LockAddress(ctx, addressID, true)
+		if err != nil {
+			return err
+		}
+
+		permission, err := AddressPermission(ctx, s.repo, userID, address)
+		if err != nil {
+			return err
+		}
 
-	err = s.repo.DeleteAddress(ctx, address.ID)
+		if !permission.Allows(PermissionEditor) {
+			return common.AuthorizationError{Message: "unauthorized restore"}
+		}
+
+		err = s.repo.RestoreAddress(ctx, addressID)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *AddressService) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+	n, err := s.repo.PurgeDeletedAddresses(ctx, time.Now().Add(-retention))
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return n, nil
 }
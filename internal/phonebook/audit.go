@@ -0,0 +1,200 @@
+package phonebook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"template/internal/common"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditRegister       AuditAction = "register"
+	AuditLogin          AuditAction = "login"
+	AuditPasswordChange AuditAction = "password_change"
+	AuditEmailChange    AuditAction = "email_change"
+	AuditAddressDelete  AuditAction = "address_delete"
+)
+
+type AuditOutcome string
+
+const (
+	AuditSuccess AuditOutcome = "success"
+	AuditFailure AuditOutcome = "failure"
+)
+
+// AuditEvent is one entry of the append-only audit log. It is also the
+// line format of the JSON lines export.
+type AuditEvent struct {
+	ID             int          `json:"id"`
+	OrganizationID int          `json:"organization_id,omitempty"`
+	ActorID        int          `json:"actor_id,omitempty"`
+	Action         AuditAction  `json:"action"`
+	Target         string       `json:"target"`
+	IP             string       `json:"ip"`
+	UserAgent      string       `json:"user_agent"`
+	Outcome        AuditOutcome `json:"outcome"`
+	Reason         string       `json:"reason,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// AuditFilter selects audit events, newest first. Zero fields do not filter;
+// BeforeID pages through the log by continuing below the last ID seen.
+type AuditFilter struct {
+	OrganizationID int
+	ActorID        int
+	Action         AuditAction
+	Outcome        AuditOutcome
+	Since          time.Time
+	Until          time.Time
+	BeforeID       int
+	Limit          int
+}
+
+const (
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+)
+
+type AuditRecorder interface {
+	NewAuditEvent(context.Context, *AuditEvent) error
+}
+
+type AuditRepository interface {
+	AuditRecorder
+	GetAuditEvents(context.Context, *AuditFilter) ([]*AuditEvent, error)
+	GetMembership(ctx context.Context, organizationID int, userID int) (*Membership, error)
+}
+
+// recordAudit appends event with the outcome of err, filling in whatever
+// ctx knows about the actor, tenant and client. It returns err, or the
+// failure to record a successful operation, so callers can return it as is.
+func recordAudit(ctx context.Context, repo AuditRecorder, event *AuditEvent, err error) error {
+	if event.ActorID == 0 {
+		event.ActorID, _ = common.ActorID(ctx)
+	}
+
+	if event.OrganizationID == 0 {
+		event.OrganizationID, _ = common.TenantID(ctx)
+	}
+
+	client := common.ClientFrom(ctx)
+	event.IP = client.IP
+	event.UserAgent = client.UserAgent
+
+	event.Outcome = AuditSuccess
+	if err != nil {
+		event.Outcome = AuditFailure
+		event.Reason = "internal error"
+
+		var ce common.ClientError
+		if errors.As(err, &ce) {
+			event.Reason = ce.Error()
+		}
+	}
+
+	recordErr := repo.NewAuditEvent(ctx, event)
+	if recordErr != nil && err == nil {
+		return recordErr
+	}
+
+	if recordErr != nil {
+		common.Log.Errorf("record audit event %s: %s", event.Action, recordErr)
+	}
+
+	return err
+}
+
+type AuditService struct {
+	repo AuditRepository
+}
+
+func NewAuditService(repo AuditRepository) *AuditService {
+	return &AuditService{repo}
+}
+
+// Events lists the audit log of filter.OrganizationID to one of its admins.
+func (s *AuditService) Events(ctx context.Context, userID int, filter *AuditFilter) ([]*AuditEvent, error) {
+	err := s.admin(ctx, userID, filter.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditLimit
+	}
+
+	if filter.Limit > MaxAuditLimit {
+		filter.Limit = MaxAuditLimit
+	}
+
+	events, err := s.repo.GetAuditEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Export writes the audit log of filter.OrganizationID to w as JSON lines
+// for one of its admins.
+func (s *AuditService) Export(ctx context.Context, userID int, filter *AuditFilter, w io.Writer) error {
+	err := s.admin(ctx, userID, filter.OrganizationID)
+	if err != nil {
+		return err
+	}
+
+	return s.ExportJSONL(ctx, filter, w)
+}
+
+// ExportJSONL writes every event matching filter to w, one JSON object per
+// line, newest first. filter.Limit sets the page size; a zero
+// OrganizationID exports every organization, so callers must check access.
+func (s *AuditService) ExportJSONL(ctx context.Context, filter *AuditFilter, w io.Writer) error {
+	page := *filter
+	if page.Limit <= 0 || page.Limit > MaxAuditLimit {
+		page.Limit = MaxAuditLimit
+	}
+
+	enc := json.NewEncoder(w)
+
+	for {
+		events, err := s.repo.GetAuditEvents(ctx, &page)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			err = enc.Encode(event)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(events) < page.Limit {
+			return nil
+		}
+
+		page.BeforeID = events[len(events)-1].ID
+	}
+}
+
+func (s *AuditService) admin(ctx context.Context, userID int, organizationID int) error {
+	membership, err := s.repo.GetMembership(ctx, organizationID, userID)
+	if errors.As(err, &common.NotFoundError{}) {
+		return common.AuthorizationError{Message: "audit log is restricted to organization admins"}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if membership.Role != RoleAdmin {
+		return common.AuthorizationError{Message: "audit log is restricted to organization admins"}
+	}
+
+	return nil
+}
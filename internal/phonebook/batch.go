@@ -0,0 +1,135 @@
+package phonebook
+
+import (
+	"context"
+	"fmt"
+	"template/internal/common"
+)
+
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// DefaultMaxBatchSize caps the number of operations accepted by a single
+// batch unless the service is given another limit.
+const DefaultMaxBatchSize = 100
+
+// BatchOperation is one step of a batch. Updates and deletes identify their
+// target by Address.ID and, when Address.Version is set, only apply to that
+// version.
+type BatchOperation struct {
+	Op      BatchOp
+	Address *Address
+}
+
+type BatchResult struct {
+	Op      BatchOp
+	Address *Address
+	Applied bool
+	Err     error
+}
+
+// Batch applies ops on behalf of userID. Atomic batches run in a single
+// transaction and leave nothing applied if any operation fails; otherwise
+// every operation is attempted on its own. The outcome of each operation is
+// reported in the result at the same index.
+func (s *AddressService) Batch(ctx context.Context, userID int, ops []*BatchOperation, atomic bool) ([]*BatchResult, error) {
+	if len(ops) == 0 {
+		return nil, common.InvariantError{Message: "batch is empty"}
+	}
+
+	if len(ops) > s.maxBatchSize {
+		return nil, common.InvariantError{Message: fmt.Sprintf("batch exceeds %d operations", s.maxBatchSize)}
+	}
+
+	results := make([]*BatchResult, len(ops))
+	invalid := false
+	for i, op := range ops {
+		results[i] = &BatchResult{Op: op.Op, Address: op.Address}
+
+		err := validateBatchOperation(op)
+		if err != nil {
+			results[i].Err = err
+			invalid = true
+		}
+	}
+
+	if !atomic {
+		for i, op := range ops {
+			if results[i].Err != nil {
+				continue
+			}
+
+			err := s.applyBatchOperation(ctx, userID, op)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+
+			results[i].Applied = true
+		}
+
+		return results, nil
+	}
+
+	if invalid {
+		return results, nil
+	}
+
+	failed := -1
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		for i, op := range ops {
+			err := s.applyBatchOperation(ctx, userID, op)
+			if err != nil {
+				failed = i
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if failed >= 0 {
+		results[failed].Err = err
+		return results, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		result.Applied = true
+	}
+
+	return results, nil
+}
+
+func validateBatchOperation(op *BatchOperation) error {
+	if op.Address == nil {
+		return common.InvariantError{Message: "missing address"}
+	}
+
+	switch op.Op {
+	case BatchCreate, BatchUpdate, BatchDelete:
+	default:
+		return common.InvariantError{Message: fmt.Sprintf("unknown op %q", op.Op)}
+	}
+
+	return nil
+}
+
+func (s *AddressService) applyBatchOperation(ctx context.Context, userID int, op *BatchOperation) error {
+	switch op.Op {
+	case BatchCreate:
+		return s.NewAddress(ctx, userID, op.Address)
+	case BatchUpdate:
+		return s.UpdateAddress(ctx, userID, op.Address.ID, op.Address)
+	default:
+		return s.DeleteAddress(ctx, userID, op.Address.ID, op.Address.Version)
+	}
+}
@@ -0,0 +1,25 @@
+package phonebook
+
+import (
+	"context"
+	"errors"
+	"template/internal/common"
+	"testing"
+)
+
+func TestBatchSizeLimit(t *testing.T) {
+	svc := NewAddressService(nil, 2)
+
+	ops := []*BatchOperation{
+		{Op: BatchDelete, Address: &Address{ID: 1}},
+		{Op: BatchDelete, Address: &Address{ID: 2}},
+		{Op: BatchDelete, Address: &Address{ID: 3}},
+	}
+
+	_, err := svc.Batch(context.Background(), 1, ops, true)
+
+	var ie common.InvariantError
+	if !errors.As(err, &ie) || ie.Message != "batch exceeds 2 operations" {
+		t.Fatalf("Batch of 3 with a limit of 2 = %v, want an invariant error", err)
+	}
+}
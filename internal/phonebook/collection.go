@@ -0,0 +1,337 @@
+package phonebook
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"template/internal/common"
+	"time"
+)
+
+type Permission string
+
+const (
+	PermissionNone   Permission = ""
+	PermissionViewer Permission = "viewer"
+	PermissionEditor Permission = "editor"
+	PermissionOwner  Permission = "owner"
+)
+
+var permissionRank = map[Permission]int{
+	PermissionNone:   0,
+	PermissionViewer: 1,
+	PermissionEditor: 2,
+	PermissionOwner:  3,
+}
+
+func (p Permission) Allows(required Permission) bool {
+	return permissionRank[p] >= permissionRank[required]
+}
+
+type Collection struct {
+	ID    int
+	Owner *User
+	Name  string
+}
+
+type Share struct {
+	ID         int
+	Collection *Collection
+	User       *User
+	Permission Permission
+	CreatedAt  time.Time
+	AcceptedAt *time.Time
+}
+
+type PermissionRepository interface {
+	GetCollectionPermission(ctx context.Context, collectionID int, userID int) (Permission, error)
+}
+
+type CollectionRepository interface {
+	PermissionRepository
+	NewCollection(context.Context, *Collection) error
+	GetCollectionsByUserID(context.Context, int) ([]*Collection, error)
+	GetCollectionByID(context.Context, int) (*Collection, error)
+	DeleteCollection(context.Context, int) error
+	GetUserByEmail(context.Context, string) (*User, error)
+	NewShare(context.Context, *Share) error
+	GetShareByID(context.Context, int) (*Share, error)
+	GetSharesByCollectionID(context.Context, int) ([]*Share, error)
+	GetPendingSharesByUserID(context.Context, int) ([]*Share, error)
+	AcceptShare(context.Context, int) error
+	DeleteShare(context.Context, int) error
+	NewAddress(context.Context, *Address) error
+	GetAddressByID(context.Context, int) (*Address, error)
+	GetAddressesByCollectionID(context.Context, int) ([]*Address, error)
+	SetAddressCollection(ctx context.Context, addressID int, collectionID *int) error
+}
+
+// AddressPermission is what userID may do with address: owners have full
+// access and everyone else gets what the address's collection was shared
+// with them as.
+func AddressPermission(ctx context.Context, repo PermissionRepository, userID int, address *Address) (Permission, error) {
+	if address.User.ID == userID {
+		return PermissionOwner, nil
+	}
+
+	if address.Collection == nil {
+		return PermissionNone, nil
+	}
+
+	return repo.GetCollectionPermission(ctx, address.Collection.ID, userID)
+}
+
+type CollectionService struct {
+	repo CollectionRepository
+}
+
+func NewCollectionService(repo CollectionRepository) *CollectionService {
+	return &CollectionService{repo}
+}
+
+func (s *CollectionService) NewCollection(ctx context.Context, userID int, collection *Collection) error {
+	collection.Owner = &User{ID: userID}
+	collection.Name = strings.TrimSpace(collection.Name)
+
+	if collection.Name == "" {
+		return common.InvariantError{Message: "collection name is required"}
+	}
+
+	err := s.repo.NewCollection(ctx, collection)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *CollectionService) GetCollectionsByUserID(ctx context.Context, userID int) ([]*Collection, error) {
+	collections, err := s.repo.GetCollectionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+func (s *CollectionService) GetCollectionByID(ctx context.Context, userID int, collectionID int) (*Collection, error) {
+	return s.authorize(ctx, userID, collectionID, PermissionViewer, "unauthorized read")
+}
+
+func (s *CollectionService) DeleteCollection(ctx context.Context, userID int, collectionID int) error {
+	_, err := s.authorize(ctx, userID, collectionID, PermissionOwner, "unauthorized delete")
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteCollection(ctx, collectionID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *CollectionService) GetAddressesByCollectionID(ctx context.Context, userID int, collectionID int) ([]*Address, error) {
+	_, err := s.authorize(ctx, userID, collectionID, PermissionViewer, "unauthorized read")
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := s.repo.GetAddressesByCollectionID(ctx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+// NewAddress creates an address inside a collection. The address belongs to
+// the collection owner, so editors add to the shared phonebook rather than
+// their own.
+func (s *CollectionService) NewAddress(ctx context.Context, userID int, collectionID int, address *Address) error {
+	collection, err := s.authorize(ctx, userID, collectionID, PermissionEditor, "unauthorized create")
+	if err != nil {
+		return err
+	}
+
+	address.User = collection.Owner
+	address.Collection = collection
+
+	err = s.repo.NewAddress(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *CollectionService) AddAddress(ctx context.Context, userID int, collectionID int, addressID int) error {
+	_, err := s.ownedAddress(ctx, userID, collectionID, addressID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.SetAddressCollection(ctx, addressID, &collectionID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *CollectionService) RemoveAddress(ctx context.Context, userID int, collectionID int, addressID int) error {
+	address, err := s.ownedAddress(ctx, userID, collectionID, addressID)
+	if err != nil {
+		return err
+	}
+
+	if address.Collection == nil || address.Collection.ID != collectionID {
+		return common.NotFoundError{Message: "address not in collection"}
+	}
+
+	err = s.repo.SetAddressCollection(ctx, addressID, nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *CollectionService) Invite(ctx context.Context, userID int, collectionID int, email string, permission Permission) (*Share, error) {
+	collection, err := s.authorize(ctx, userID, collectionID, PermissionOwner, "unauthorized invite")
+	if err != nil {
+		return nil, err
+	}
+
+	if permission != PermissionViewer && permission != PermissionEditor {
+		return nil, common.InvariantError{Message: "permission must be viewer or editor"}
+	}
+
+	invitee, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if invitee.ID == userID {
+		return nil, common.InvariantError{Message: "cannot share with yourself"}
+	}
+
+	share := &Share{
+		Collection: collection,
+		User:       &User{ID: invitee.ID, Email: invitee.Email},
+		Permission: permission,
+	}
+
+	err = s.repo.NewShare(ctx, share)
+	if err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+func (s *CollectionService) GetSharesByCollectionID(ctx context.Context, userID int, collectionID int) ([]*Share, error) {
+	_, err := s.authorize(ctx, userID, collectionID, PermissionOwner, "unauthorized read")
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := s.repo.GetSharesByCollectionID(ctx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+func (s *CollectionService) Invitations(ctx context.Context, userID int) ([]*Share, error) {
+	shares, err := s.repo.GetPendingSharesByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+func (s *CollectionService) AcceptInvitation(ctx context.Context, userID int, shareID int) error {
+	share, err := s.repo.GetShareByID(ctx, shareID)
+	if errors.As(err, &common.NotFoundError{}) {
+		return common.NotFoundError{Message: "invitation not found"}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if share.User.ID != userID {
+		return common.NotFoundError{Message: "invitation not found"}
+	}
+
+	if share.AcceptedAt != nil {
+		return common.InvariantError{Message: "invitation already accepted"}
+	}
+
+	err = s.repo.AcceptShare(ctx, shareID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeShare lets the collection owner revoke a share, and the invitee
+// decline an invitation or leave a collection.
+func (s *CollectionService) RevokeShare(ctx context.Context, userID int, shareID int) error {
+	share, err := s.repo.GetShareByID(ctx, shareID)
+	if err != nil {
+		return err
+	}
+
+	if share.User.ID != userID && share.Collection.Owner.ID != userID {
+		return common.AuthorizationError{Message: "unauthorized revoke"}
+	}
+
+	err = s.repo.DeleteShare(ctx, shareID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *CollectionService) authorize(ctx context.Context, userID int, collectionID int, required Permission, message string) (*Collection, error) {
+	collection, err := s.repo.GetCollectionByID(ctx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	permission, err := s.repo.GetCollectionPermission(ctx, collectionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !permission.Allows(required) {
+		return nil, common.AuthorizationError{Message: message}
+	}
+
+	return collection, nil
+}
+
+func (s *CollectionService) ownedAddress(ctx context.Context, userID int, collectionID int, addressID int) (*Address, error) {
+	_, err := s.authorize(ctx, userID, collectionID, PermissionOwner, "unauthorized update")
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := s.repo.GetAddressByID(ctx, addressID)
+	if err != nil {
+		return nil, err
+	}
+
+	if address.User.ID != userID {
+		return nil, common.AuthorizationError{Message: "unauthorized update"}
+	}
+
+	return address, nil
+}
@@ -0,0 +1,183 @@
+package phonebook
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"template/internal/common"
+)
+
+const (
+	ColumnName        = "name"
+	ColumnPhoneNumber = "phone_number"
+)
+
+// ColumnMapping maps an address field (ColumnName, ColumnPhoneNumber) to the
+// CSV header that holds it. Fields left out are read from the header of the
+// same name.
+type ColumnMapping map[string]string
+
+type RowError struct {
+	Line   int      `json:"line"`
+	Errors []string `json:"errors"`
+}
+
+type ImportResult struct {
+	DryRun   bool       `json:"dry_run"`
+	Total    int        `json:"total"`
+	Valid    int        `json:"valid"`
+	Imported int        `json:"imported"`
+	Errors   []RowError `json:"errors"`
+}
+
+func (m ColumnMapping) column(field string) string {
+	if col, ok := m[field]; ok && col != "" {
+		return col
+	}
+
+	return field
+}
+
+func (m ColumnMapping) validate() error {
+	for field := range m {
+		if field != ColumnName && field != ColumnPhoneNumber {
+			return common.InvariantError{Message: fmt.Sprintf("unknown mapping field %q", field)}
+		}
+	}
+
+	return nil
+}
+
+func (s *AddressService) ImportCSV(ctx context.Context, userID int, r io.Reader, mapping ColumnMapping, dryRun bool) (*ImportResult, error) {
+	if err := mapping.validate(); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, common.InvariantError{Message: "CSV is empty"}
+	}
+	if err != nil {
+		return nil, common.InvariantError{Message: fmt.Sprintf("invalid CSV header: %s", err)}
+	}
+
+	index := make(map[string]int, len(header))
+	for i, col := range header {
+		index[strings.TrimSpace(col)] = i
+	}
+
+	nameIdx, ok := index[mapping.column(ColumnName)]
+	if !ok {
+		return nil, common.InvariantError{Message: fmt.Sprintf("missing column %q", mapping.column(ColumnName))}
+	}
+
+	phoneIdx, ok := index[mapping.column(ColumnPhoneNumber)]
+	if !ok {
+		return nil, common.InvariantError{Message: fmt.Sprintf("missing column %q", mapping.column(ColumnPhoneNumber))}
+	}
+
+	result := &ImportResult{DryRun: dryRun, Errors: make([]RowError, 0)}
+	addresses := make([]*Address, 0)
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			result.Total++
+			result.Errors = append(result.Errors, RowError{Line: pe.StartLine, Errors: []string{pe.Err.Error()}})
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.Total++
+		line, _ := reader.FieldPos(0)
+
+		address := &Address{
+			User:        &User{ID: userID},
+			Name:        csvField(record, nameIdx),
+			PhoneNumber: csvField(record, phoneIdx),
+		}
+
+		if errs := ValidateAddress(address); len(errs) > 0 {
+			result.Errors = append(result.Errors, RowError{Line: line, Errors: errs})
+			continue
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	result.Valid = len(addresses)
+
+	if dryRun || len(addresses) == 0 {
+		return result, nil
+	}
+
+	err = s.repo.NewAddresses(ctx, addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Imported = len(addresses)
+
+	return result, nil
+}
+
+func (s *AddressService) ExportCSV(ctx context.Context, userID int, w io.Writer) error {
+	addresses, err := s.repo.GetAddressesByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+
+	err = writer.Write([]string{"id", ColumnName, ColumnPhoneNumber})
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		err = writer.Write([]string{strconv.Itoa(address.ID), csvEscape(address.Name), address.PhoneNumber})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// csvEscape keeps spreadsheets from evaluating a cell as a formula by
+// prefixing cells that start with a formula character with a quote. Only
+// free text needs it: phone numbers match phoneNumberPattern, and escaping
+// them would mangle every international number.
+func csvEscape(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+
+	return value
+}
+
+func csvField(record []string, idx int) string {
+	if idx >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[idx])
+}
@@ -0,0 +1,199 @@
+package phonebook_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"template/internal/repository"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	repo := newRepository(t)
+	svc := phonebook.NewAddressService(repo, phonebook.DefaultMaxBatchSize)
+
+	aliceCtx, aliceID := newTenant(t, repo, "alice@example.com")
+	bobCtx, bobID := newTenant(t, repo, "bob@example.com")
+
+	want := []*phonebook.Address{
+		{User: &phonebook.User{ID: aliceID}, Name: "Ann", PhoneNumber: "+49 30 1234567"},
+		{User: &phonebook.User{ID: aliceID}, Name: "=Carol, \"C\"", PhoneNumber: "(555) 010-0000"},
+	}
+	err := repo.NewAddresses(aliceCtx, want)
+	if err != nil {
+		t.Fatalf("NewAddresses: %s", err)
+	}
+
+	var buf bytes.Buffer
+	err = svc.ExportCSV(aliceCtx, aliceID, &buf)
+	if err != nil {
+		t.Fatalf("ExportCSV: %s", err)
+	}
+
+	result, err := svc.ImportCSV(bobCtx, bobID, &buf, nil, false)
+	if err != nil {
+		t.Fatalf("ImportCSV: %s", err)
+	}
+
+	if result.Imported != len(want) || len(result.Errors) != 0 {
+		t.Fatalf("ImportCSV of an export = %+v, want every row imported", result)
+	}
+
+	got, err := repo.GetAddressesByUserID(bobCtx, bobID)
+	if err != nil {
+		t.Fatalf("GetAddressesByUserID: %s", err)
+	}
+
+	if len(got) != 2 || got[0].PhoneNumber != "+49 30 1234567" || got[1].Name != "'=Carol, \"C\"" {
+		t.Fatalf("round trip = %+v %+v, want phone numbers intact and formulas escaped", got[0], got[1])
+	}
+}
+
+// failingRepository counts NewAddresses calls and can make them fail.
+type failingRepository struct {
+	*repository.SQLiteRepository
+	calls int
+	err   error
+}
+
+func (r *failingRepository) NewAddresses(ctx context.Context, addresses []*phonebook.Address) error {
+	r.calls++
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.SQLiteRepository.NewAddresses(ctx, addresses)
+}
+
+func TestImportCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		csv      string
+		mapping  phonebook.ColumnMapping
+		dryRun   bool
+		total    int
+		imported int
+		errors   []phonebook.RowError
+	}{
+		{
+			name:     "header names",
+			csv:      "name,phone_number\nAnn,+1 555 0100\nBob,+1 555 0101\n",
+			total:    2,
+			imported: 2,
+		},
+		{
+			name:     "column mapping",
+			csv:      "Tel,Extra,Full Name\n+1 555 0100,x,Ann\n",
+			mapping:  phonebook.ColumnMapping{phonebook.ColumnName: "Full Name", phonebook.ColumnPhoneNumber: "Tel"},
+			total:    1,
+			imported: 1,
+		},
+		{
+			name:   "dry run",
+			csv:    "name,phone_number\nAnn,+1 555 0100\n",
+			dryRun: true,
+			total:  1,
+		},
+		{
+			name:     "invalid rows by line",
+			csv:      "name,phone_number\nAnn,+1 555 0100\n,abc\n\"Multi\nline\",+1 555 0101\nBob,\n",
+			total:    4,
+			imported: 2,
+			errors: []phonebook.RowError{
+				{Line: 3, Errors: []string{"name is required", "phone_number is invalid"}},
+				{Line: 6, Errors: []string{"phone_number is required"}},
+			},
+		},
+		{
+			name:     "parse error",
+			csv:      "name,phone_number\nAnn,+1 555 0100\nB\"ob,+1 555 0101\n",
+			total:    2,
+			imported: 1,
+			errors: []phonebook.RowError{
+				{Line: 3, Errors: []string{`bare " in non-quoted-field`}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &failingRepository{SQLiteRepository: newRepository(t)}
+			svc := phonebook.NewAddressService(repo, phonebook.DefaultMaxBatchSize)
+			ctx, userID := newTenant(t, repo.SQLiteRepository, "alice@example.com")
+
+			result, err := svc.ImportCSV(ctx, userID, strings.NewReader(tt.csv), tt.mapping, tt.dryRun)
+			if err != nil {
+				t.Fatalf("ImportCSV: %s", err)
+			}
+
+			if result.Total != tt.total || result.Imported != tt.imported || result.DryRun != tt.dryRun {
+				t.Fatalf("ImportCSV = %+v, want %d rows with %d imported", result, tt.total, tt.imported)
+			}
+
+			if len(result.Errors) != len(tt.errors) || len(tt.errors) > 0 && !reflect.DeepEqual(result.Errors, tt.errors) {
+				t.Fatalf("row errors = %v, want %v", result.Errors, tt.errors)
+			}
+
+			addresses, err := repo.GetAddressesByUserID(ctx, userID)
+			if err != nil {
+				t.Fatalf("GetAddressesByUserID: %s", err)
+			}
+
+			if len(addresses) != tt.imported {
+				t.Fatalf("stored %d addresses, want %d", len(addresses), tt.imported)
+			}
+
+			if tt.imported > 0 && repo.calls != 1 {
+				t.Fatalf("NewAddresses called %d times, want the valid rows written at once", repo.calls)
+			}
+		})
+	}
+}
+
+func TestImportCSVRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		mapping phonebook.ColumnMapping
+	}{
+		{"empty", "", nil},
+		{"missing column", "name,phone\nAnn,1\n", nil},
+		{"missing mapped column", "name,phone_number\nAnn,1\n", phonebook.ColumnMapping{phonebook.ColumnName: "Full Name"}},
+		{"unknown mapping field", "name,phone_number\nAnn,1\n", phonebook.ColumnMapping{"email": "Email"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepository(t)
+			svc := phonebook.NewAddressService(repo, phonebook.DefaultMaxBatchSize)
+			ctx, userID := newTenant(t, repo, "alice@example.com")
+
+			_, err := svc.ImportCSV(ctx, userID, strings.NewReader(tt.csv), tt.mapping, false)
+
+			var ie common.InvariantError
+			if !errors.As(err, &ie) {
+				t.Fatalf("ImportCSV = %v, want an invariant error", err)
+			}
+		})
+	}
+}
+
+func TestImportCSVAllOrNothing(t *testing.T) {
+	repo := &failingRepository{SQLiteRepository: newRepository(t), err: errors.New("disk full")}
+	svc := phonebook.NewAddressService(repo, phonebook.DefaultMaxBatchSize)
+	ctx, userID := newTenant(t, repo.SQLiteRepository, "alice@example.com")
+
+	_, err := svc.ImportCSV(ctx, userID, strings.NewReader("name,phone_number\nAnn,1\nBob,2\n"), nil, false)
+	if err == nil {
+		t.Fatal("ImportCSV succeeded although the write failed")
+	}
+
+	addresses, err := repo.GetAddressesByUserID(ctx, userID)
+	if err != nil || len(addresses) != 0 {
+		t.Fatalf("GetAddressesByUserID = %v, %v, want nothing imported", addresses, err)
+	}
+}
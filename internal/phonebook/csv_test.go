@@ -0,0 +1,25 @@
+package phonebook
+
+import "testing"
+
+func TestCSVEscape(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"Ann", "Ann"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+SUM(A1)", "'+SUM(A1)"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"a=b", "a=b"},
+	}
+
+	for _, tt := range tests {
+		if got := csvEscape(tt.value); got != tt.want {
+			t.Errorf("csvEscape(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
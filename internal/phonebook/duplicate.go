@@ -0,0 +1,354 @@
+package phonebook
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"template/internal/common"
+	"time"
+)
+
+const (
+	ReasonPhoneNumber = "phone_number"
+	ReasonName        = "name"
+
+	// NameSimilarityThreshold is the trigram similarity from which two names
+	// count as the same.
+	NameSimilarityThreshold = 0.5
+
+	// MaxDuplicatePairs caps the pairs a duplicate scan reports.
+	MaxDuplicatePairs = 1000
+	// MaxDuplicateScan caps the addresses FindDuplicatePairs compares, since
+	// it compares every address with every other.
+	MaxDuplicateScan = 2000
+)
+
+// DuplicateCluster is a group of addresses that all look like the same
+// contact as each other.
+type DuplicateCluster struct {
+	Addresses []*Address
+	Reasons   []string
+}
+
+// DuplicatePair is two addresses, by ID, that look like the same contact.
+type DuplicatePair struct {
+	First   int
+	Second  int
+	Reasons []string
+}
+
+// Merge folds duplicates into a survivor. The duplicates go to the trash,
+// so undoing the merge restores them with their tags and history; that is
+// only possible while the survivor is unchanged since the merge.
+type Merge struct {
+	ID       int
+	User     *User
+	Survivor *Address
+	// Previous holds the survivor as it was before the merge.
+	Previous  *Address
+	Merged    []*Address
+	CreatedAt time.Time
+	UndoneAt  *time.Time
+}
+
+type DuplicateRepository interface {
+	GetAddressesByUserID(context.Context, int) ([]*Address, error)
+	// GetDuplicatePairs lists up to limit pairs of the user's addresses that
+	// share a normalized phone number or have names at least
+	// NameSimilarityThreshold alike.
+	GetDuplicatePairs(ctx context.Context, userID int, limit int) ([]DuplicatePair, error)
+	GetAddressByID(context.Context, int) (*Address, error)
+	MergeAddresses(context.Context, *Merge) error
+	GetMergeByID(context.Context, int) (*Merge, error)
+	UndoMerge(context.Context, *Merge) error
+}
+
+type DuplicateService struct {
+	repo DuplicateRepository
+}
+
+func NewDuplicateService(repo DuplicateRepository) *DuplicateService {
+	return &DuplicateService{repo}
+}
+
+func (s *DuplicateService) FindDuplicates(ctx context.Context, userID int) ([]*DuplicateCluster, error) {
+	addresses, err := s.repo.GetAddressesByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, err := s.repo.GetDuplicatePairs(ctx, userID, MaxDuplicatePairs)
+	if err != nil {
+		return nil, err
+	}
+
+	return ClusterDuplicates(addresses, pairs), nil
+}
+
+func (s *DuplicateService) Merge(ctx context.Context, userID int, survivorID int, duplicateIDs []int, override *Address) (*Merge, error) {
+	if len(duplicateIDs) == 0 {
+		return nil, common.InvariantError{Message: "nothing to merge"}
+	}
+
+	survivor, err := s.ownedAddress(ctx, userID, survivorID)
+	if err != nil {
+		return nil, err
+	}
+
+	merge := &Merge{
+		User:     &User{ID: userID},
+		Previous: survivor,
+		Merged:   make([]*Address, 0, len(duplicateIDs)),
+	}
+
+	seen := map[int]bool{survivorID: true}
+	for _, id := range duplicateIDs {
+		if seen[id] {
+			return nil, common.InvariantError{Message: "duplicate IDs must be distinct from each other and the survivor"}
+		}
+		seen[id] = true
+
+		address, err := s.ownedAddress(ctx, userID, id)
+		if err != nil {
+			return nil, err
+		}
+
+		merge.Merged = append(merge.Merged, address)
+	}
+
+	merge.Survivor = combine(survivor, merge.Merged, override)
+
+	err = s.repo.MergeAddresses(ctx, merge)
+	if err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+func (s *DuplicateService) UndoMerge(ctx context.Context, userID int, mergeID int) error {
+	merge, err := s.repo.GetMergeByID(ctx, mergeID)
+	if err != nil {
+		return err
+	}
+
+	if merge.User.ID != userID {
+		return common.AuthorizationError{Message: "unauthorized undo"}
+	}
+
+	if merge.UndoneAt != nil {
+		return common.InvariantError{Message: "merge already undone"}
+	}
+
+	err = s.repo.UndoMerge(ctx, merge)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *DuplicateService) ownedAddress(ctx context.Context, userID int, addressID int) (*Address, error) {
+	address, err := s.repo.GetAddressByID(ctx, addressID)
+	if err != nil {
+		return nil, err
+	}
+
+	if address.User.ID != userID {
+		return nil, common.AuthorizationError{Message: "unauthorized merge"}
+	}
+
+	return address, nil
+}
+
+// combine fills every field of the surviving contact from the override, then
+// the survivor itself, then the first duplicate that has it.
+func combine(survivor *Address, merged []*Address, override *Address) *Address {
+	res := &Address{ID: survivor.ID, User: survivor.User}
+
+	pick := func(get func(*Address) string) string {
+		if override != nil && get(override) != "" {
+			return get(override)
+		}
+
+		if get(survivor) != "" {
+			return get(survivor)
+		}
+
+		for _, address := range merged {
+			if get(address) != "" {
+				return get(address)
+			}
+		}
+
+		return ""
+	}
+
+	res.Name = pick(func(a *Address) string { return a.Name })
+	res.PhoneNumber = pick(func(a *Address) string { return a.PhoneNumber })
+
+	return res
+}
+
+// NormalizePhoneNumber reduces a phone number to its subscriber part so that
+// the same number written with or without a country or trunk prefix matches.
+func NormalizePhoneNumber(phoneNumber string) string {
+	digits := strings.TrimLeft(Digits(phoneNumber), "0")
+	if len(digits) > 9 {
+		digits = digits[len(digits)-9:]
+	}
+
+	return digits
+}
+
+// FindDuplicatePairs is the duplicate scan used by repositories without
+// trigram support. It compares the first MaxDuplicateScan addresses with each
+// other and stops after limit pairs.
+func FindDuplicatePairs(addresses []*Address, limit int) []DuplicatePair {
+	if len(addresses) > MaxDuplicateScan {
+		addresses = addresses[:MaxDuplicateScan]
+	}
+
+	phones := make([]string, len(addresses))
+	grams := make([]map[string]bool, len(addresses))
+	for i, address := range addresses {
+		phones[i] = NormalizePhoneNumber(address.PhoneNumber)
+		grams[i] = trigrams(address.Name)
+	}
+
+	res := make([]DuplicatePair, 0)
+	for i := range addresses {
+		for j := i + 1; j < len(addresses); j++ {
+			var reasons []string
+			if phones[i] != "" && phones[i] == phones[j] {
+				reasons = append(reasons, ReasonPhoneNumber)
+			}
+			if similarity(grams[i], grams[j]) >= NameSimilarityThreshold {
+				reasons = append(reasons, ReasonName)
+			}
+
+			if len(reasons) == 0 {
+				continue
+			}
+
+			res = append(res, DuplicatePair{First: addresses[i].ID, Second: addresses[j].ID, Reasons: reasons})
+			if len(res) == limit {
+				return res
+			}
+		}
+	}
+
+	return res
+}
+
+// ClusterDuplicates groups addresses so that every two addresses of a
+// cluster form one of the pairs. Pairs do not chain: when A looks like B and
+// B like C but A not like C, A and B form a cluster and C is left out.
+// Addresses are taken in order, so each joins the earliest cluster it fits.
+func ClusterDuplicates(addresses []*Address, pairs []DuplicatePair) []*DuplicateCluster {
+	type key struct{ first, second int }
+	alike := make(map[key][]string, len(pairs))
+	neighbours := make(map[int]map[int]bool)
+	for _, pair := range pairs {
+		first, second := pair.First, pair.Second
+		if first > second {
+			first, second = second, first
+		}
+		alike[key{first, second}] = append(alike[key{first, second}], pair.Reasons...)
+
+		for _, id := range []int{first, second} {
+			if neighbours[id] == nil {
+				neighbours[id] = make(map[int]bool)
+			}
+		}
+		neighbours[first][second] = true
+		neighbours[second][first] = true
+	}
+
+	reasons := func(a, b int) []string {
+		if a > b {
+			a, b = b, a
+		}
+		return alike[key{a, b}]
+	}
+
+	clustered := make(map[int]bool)
+	res := make([]*DuplicateCluster, 0)
+	for i, seed := range addresses {
+		if clustered[seed.ID] || len(neighbours[seed.ID]) == 0 {
+			continue
+		}
+
+		members := []*Address{seed}
+		found := make(map[string]bool)
+		for _, candidate := range addresses[i+1:] {
+			if clustered[candidate.ID] || !neighbours[seed.ID][candidate.ID] {
+				continue
+			}
+
+			fits := true
+			for _, member := range members[1:] {
+				if !neighbours[member.ID][candidate.ID] {
+					fits = false
+					break
+				}
+			}
+			if !fits {
+				continue
+			}
+
+			for _, member := range members {
+				for _, r := range reasons(member.ID, candidate.ID) {
+					found[r] = true
+				}
+			}
+			members = append(members, candidate)
+		}
+
+		if len(members) < 2 {
+			continue
+		}
+
+		cluster := &DuplicateCluster{Addresses: members}
+		for _, member := range members {
+			clustered[member.ID] = true
+		}
+		for r := range found {
+			cluster.Reasons = append(cluster.Reasons, r)
+		}
+		sort.Strings(cluster.Reasons)
+
+		res = append(res, cluster)
+	}
+
+	return res
+}
+
+// trigrams splits a name the way pg_trgm does, so similarity here agrees with
+// the Postgres similarity() used by search and the duplicate scan.
+func trigrams(name string) map[string]bool {
+	res := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), isSeparator) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			res[string(padded[i:i+3])] = true
+		}
+	}
+
+	return res
+}
+
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for g := range a {
+		if b[g] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
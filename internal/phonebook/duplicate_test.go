@@ -0,0 +1,117 @@
+package phonebook
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		phoneNumber string
+		want        string
+	}{
+		{"", ""},
+		{"abc", ""},
+		{"0", ""},
+		{"555 0100", "5550100"},
+		{"+49 30 1234567", "301234567"},
+		{"0049 30 1234567", "301234567"},
+		{"030 1234567", "301234567"},
+		{"(030) 123-45-67", "301234567"},
+	}
+
+	for _, tt := range tests {
+		got := NormalizePhoneNumber(tt.phoneNumber)
+		if got != tt.want {
+			t.Errorf("NormalizePhoneNumber(%q) = %q, want %q", tt.phoneNumber, got, tt.want)
+		}
+	}
+}
+
+func TestFindDuplicatePairs(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Address
+		b    Address
+		want string
+	}{
+		{"same number, other prefix", Address{Name: "Ann", PhoneNumber: "+49 30 1234567"}, Address{Name: "Bob", PhoneNumber: "030 1234567"}, "[phone_number]"},
+		{"name above threshold", Address{Name: "Frank Miller", PhoneNumber: "1"}, Address{Name: "Frank Millar", PhoneNumber: "2"}, "[name]"},
+		{"name below threshold", Address{Name: "Frank Miller", PhoneNumber: "1"}, Address{Name: "Frank", PhoneNumber: "2"}, "[]"},
+		{"both", Address{Name: "Jon Smith", PhoneNumber: "555 0100"}, Address{Name: "John Smith", PhoneNumber: "5550100"}, "[phone_number name]"},
+		{"no digits", Address{Name: "Ann", PhoneNumber: "-"}, Address{Name: "Bob", PhoneNumber: "()"}, "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.a.ID, tt.b.ID = 1, 2
+
+			pairs := FindDuplicatePairs([]*Address{&tt.a, &tt.b}, MaxDuplicatePairs)
+
+			got := "[]"
+			if len(pairs) == 1 {
+				got = fmt.Sprint(pairs[0].Reasons)
+			}
+			if len(pairs) > 1 || got != tt.want {
+				t.Fatalf("FindDuplicatePairs = %+v, want reasons %s", pairs, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindDuplicatePairsLimit(t *testing.T) {
+	addresses := make([]*Address, 0)
+	for i := 1; i <= 4; i++ {
+		addresses = append(addresses, &Address{ID: i, Name: fmt.Sprintf("Contact %d", i), PhoneNumber: "555 0100"})
+	}
+
+	pairs := FindDuplicatePairs(addresses, 2)
+	if len(pairs) != 2 {
+		t.Fatalf("FindDuplicatePairs with a limit of 2 = %d pairs", len(pairs))
+	}
+}
+
+func TestClusterDuplicates(t *testing.T) {
+	ann := &Address{ID: 1, Name: "Ann Lee", PhoneNumber: "1"}
+	anna := &Address{ID: 2, Name: "Anna Lee", PhoneNumber: "2"}
+	leeds := &Address{ID: 3, Name: "Anna Leeds", PhoneNumber: "3"}
+	bob := &Address{ID: 4, Name: "Bob", PhoneNumber: "+1 555 0100"}
+	rob := &Address{ID: 5, Name: "Rob", PhoneNumber: "001 555-0100"}
+	carol := &Address{ID: 6, Name: "Carol", PhoneNumber: "6"}
+
+	addresses := []*Address{ann, anna, leeds, bob, rob, carol}
+	clusters := ClusterDuplicates(addresses, FindDuplicatePairs(addresses, MaxDuplicatePairs))
+
+	got := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		ids := make([]int, 0, len(cluster.Addresses))
+		for _, address := range cluster.Addresses {
+			ids = append(ids, address.ID)
+		}
+		got = append(got, fmt.Sprint(ids, cluster.Reasons))
+	}
+
+	// Ann Lee is like Anna Lee and Anna Lee like Anna Leeds, but Ann Lee is
+	// not like Anna Leeds, so the chain must not end up in one cluster.
+	want := []string{"[1 2] [name]", "[4 5] [phone_number]"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("ClusterDuplicates = %v, want %v", got, want)
+	}
+}
+
+func TestClusterDuplicatesAllAlike(t *testing.T) {
+	addresses := []*Address{
+		{ID: 1, Name: "Ann", PhoneNumber: "555 0100"},
+		{ID: 2, Name: "Ann Lee", PhoneNumber: "555-0100"},
+		{ID: 3, Name: "Anna Lee", PhoneNumber: "(555) 0100"},
+	}
+
+	clusters := ClusterDuplicates(addresses, FindDuplicatePairs(addresses, MaxDuplicatePairs))
+	if len(clusters) != 1 || len(clusters[0].Addresses) != 3 {
+		t.Fatalf("ClusterDuplicates = %+v, want one cluster of all three", clusters)
+	}
+
+	if fmt.Sprint(clusters[0].Reasons) != "[name phone_number]" {
+		t.Fatalf("reasons = %v, want name and phone_number", clusters[0].Reasons)
+	}
+}
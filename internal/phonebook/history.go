@@ -0,0 +1,113 @@
+package phonebook
+
+import (
+	"context"
+	"errors"
+	"template/internal/common"
+	"time"
+)
+
+type Action string
+
+const (
+	ActionCreate  Action = "create"
+	ActionUpdate  Action = "update"
+	ActionDelete  Action = "delete"
+	ActionRestore Action = "restore"
+	ActionRevert  Action = "revert"
+	ActionMerge   Action = "merge"
+)
+
+type Change struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AddressVersion is one recorded change of an address. Name and PhoneNumber
+// hold the address as it was right after the change.
+type AddressVersion struct {
+	AddressID   int
+	Version     int
+	Actor       *User
+	Action      Action
+	Changes     map[string]Change
+	Name        string
+	PhoneNumber string
+	CreatedAt   time.Time
+}
+
+func DiffAddresses(before *Address, after *Address) map[string]Change {
+	if before == nil {
+		before = &Address{}
+	}
+
+	changes := make(map[string]Change)
+	if before.Name != after.Name {
+		changes[ColumnName] = Change{From: before.Name, To: after.Name}
+	}
+	if before.PhoneNumber != after.PhoneNumber {
+		changes[ColumnPhoneNumber] = Change{From: before.PhoneNumber, To: after.PhoneNumber}
+	}
+
+	return changes
+}
+
+func (s *AddressService) History(ctx context.Context, userID int, addressID int) ([]*AddressVersion, error) {
+	address, err := s.repo.GetAddressByID(ctx, addressID)
+	if errors.As(err, &common.NotFoundError{}) {
+		address, err = s.repo.GetDeletedAddressByID(ctx, addressID)
+		if errors.As(err, &common.NotFoundError{}) {
+			return nil, common.NotFoundError{Message: "address not found"}
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	permission, err := AddressPermission(ctx, s.repo, userID, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if !permission.Allows(PermissionViewer) {
+		return nil, common.AuthorizationError{Message: "unauthorized read"}
+	}
+
+	versions, err := s.repo.GetAddressVersions(ctx, addressID)
+	if err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func (s *AddressService) RevertAddress(ctx context.Context, userID int, addressID int, version int) error {
+	return s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		address, err := s.repo.LockAddress(ctx, addressID, false)
+		if err != nil {
+			return err
+		}
+
+		permission, err := AddressPermission(ctx, s.repo, userID, address)
+		if err != nil {
+			return err
+		}
+
+		if !permission.Allows(PermissionEditor) {
+			return common.AuthorizationError{Message: "unauthorized revert"}
+		}
+
+		target, err := s.repo.GetAddressVersion(ctx, addressID, version)
+		if err != nil {
+			return err
+		}
+
+		err = s.repo.RevertAddress(ctx, addressID, target)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,104 @@
+package phonebook
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"template/internal/common"
+	"time"
+)
+
+// Identity is an account at an external identity provider, named by the
+// provider's issuer URL and the subject it assigns to the user.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	CreatedAt     time.Time
+}
+
+// LoginWithIdentity signs in the user linked to identity on device. An
+// identity seen for the first time is linked to the user with the same
+// email if the provider verified that address, or to a new user otherwise.
+// Users created this way have no password until they set one.
+func (s *UserService) LoginWithIdentity(ctx context.Context, identity *Identity, device string) (string, error) {
+	event := &AuditEvent{Action: AuditLogin, Target: "identity:" + identity.Subject + "@" + identity.Issuer}
+
+	token, err := s.loginWithIdentity(ctx, identity, device, event)
+
+	return token, recordAudit(ctx, s.repo, event, err)
+}
+
+func (s *UserService) loginWithIdentity(ctx context.Context, identity *Identity, device string, event *AuditEvent) (string, error) {
+	user, err := s.repo.GetUserByIdentity(ctx, identity.Issuer, identity.Subject)
+	if errors.As(err, &common.NotFoundError{}) {
+		user, err = s.linkIdentity(ctx, identity)
+	}
+
+	if err != nil {
+		return "", err
+	}
+	event.ActorID = user.ID
+
+	organizationID, err := s.defaultOrganization(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	event.OrganizationID = organizationID
+
+	token, err := issueToken(ctx, s.repo, user.ID, organizationID, device)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// linkIdentity links identity to its user, provisioning one if needed, in
+// one unit of work so a failed link leaves no user behind.
+func (s *UserService) linkIdentity(ctx context.Context, identity *Identity) (*User, error) {
+	email := strings.TrimSpace(identity.Email)
+	if email == "" {
+		return nil, common.AuthenticationError{Message: "identity provider did not share an email address"}
+	}
+
+	var user *User
+	err := s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.repo.GetUserByEmail(ctx, email)
+		switch {
+		case err == nil && !identity.EmailVerified:
+			return common.ConflictError{Message: "email already registered, sign in with your password"}
+		case errors.As(err, &common.NotFoundError{}):
+			user, err = s.provision(ctx, email, identity.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return s.repo.NewIdentity(ctx, user.ID, identity)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UserService) provision(ctx context.Context, email string, name string) (*User, error) {
+	if len(name) > 100 {
+		name = ""
+	}
+
+	var err error
+	user := &User{Email: email, DisplayName: name}
+	user.ID, err = s.repo.NewUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,185 @@
+package phonebook
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"template/internal/common"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+)
+
+type Organization struct {
+	ID        int
+	Name      string
+	CreatedAt time.Time
+}
+
+type Membership struct {
+	Organization *Organization
+	User         *User
+	Role         Role
+	CreatedAt    time.Time
+}
+
+type OrganizationRepository interface {
+	SessionIssuer
+	NewOrganization(ctx context.Context, organization *Organization, admin *User) error
+	GetOrganizationByID(context.Context, int) (*Organization, error)
+	GetMembership(ctx context.Context, organizationID int, userID int) (*Membership, error)
+	GetMembershipsByUserID(context.Context, int) ([]*Membership, error)
+	GetMembershipsByOrganizationID(context.Context, int) ([]*Membership, error)
+	NewMembership(context.Context, *Membership) error
+	DeleteMembership(ctx context.Context, organizationID int, userID int) error
+	GetUserByEmail(context.Context, string) (*User, error)
+}
+
+type OrganizationService struct {
+	repo OrganizationRepository
+}
+
+func NewOrganizationService(repo OrganizationRepository) *OrganizationService {
+	return &OrganizationService{repo}
+}
+
+func (s *OrganizationService) NewOrganization(ctx context.Context, userID int, organization *Organization) error {
+	organization.Name = strings.TrimSpace(organization.Name)
+	if organization.Name == "" {
+		return common.InvariantError{Message: "organization name is required"}
+	}
+
+	err := s.repo.NewOrganization(ctx, organization, &User{ID: userID})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *OrganizationService) GetMembershipsByUserID(ctx context.Context, userID int) ([]*Membership, error) {
+	memberships, err := s.repo.GetMembershipsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return memberships, nil
+}
+
+// SwitchOrganization issues a token for another organization the user is a
+// member of, continuing the current session.
+func (s *OrganizationService) SwitchOrganization(ctx context.Context, userID int, organizationID int) (string, error) {
+	_, err := s.member(ctx, userID, organizationID, RoleMember)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := issueToken(ctx, s.repo, userID, organizationID, "")
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *OrganizationService) Members(ctx context.Context, userID int, organizationID int) ([]*Membership, error) {
+	_, err := s.member(ctx, userID, organizationID, RoleMember)
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := s.repo.GetMembershipsByOrganizationID(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return memberships, nil
+}
+
+func (s *OrganizationService) InviteMember(ctx context.Context, userID int, organizationID int, email string, role Role) (*Membership, error) {
+	admin, err := s.member(ctx, userID, organizationID, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != RoleAdmin && role != RoleMember {
+		return nil, common.InvariantError{Message: "role must be admin or member"}
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	membership := &Membership{
+		Organization: admin.Organization,
+		User:         &User{ID: user.ID, Email: user.Email},
+		Role:         role,
+	}
+
+	err = s.repo.NewMembership(ctx, membership)
+	if err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+func (s *OrganizationService) RemoveMember(ctx context.Context, userID int, organizationID int, memberID int) error {
+	_, err := s.member(ctx, userID, organizationID, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	membership, err := s.repo.GetMembership(ctx, organizationID, memberID)
+	if err != nil {
+		return err
+	}
+
+	if membership.Role == RoleAdmin {
+		memberships, err := s.repo.GetMembershipsByOrganizationID(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+
+		admins := 0
+		for _, m := range memberships {
+			if m.Role == RoleAdmin {
+				admins++
+			}
+		}
+
+		if admins <= 1 {
+			return common.InvariantError{Message: "cannot remove the last admin"}
+		}
+	}
+
+	err = s.repo.DeleteMembership(ctx, organizationID, memberID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *OrganizationService) member(ctx context.Context, userID int, organizationID int, role Role) (*Membership, error) {
+	membership, err := s.repo.GetMembership(ctx, organizationID, userID)
+	if errors.As(err, &common.NotFoundError{}) {
+		return nil, common.NotFoundError{Message: "organization not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if role == RoleAdmin && membership.Role != RoleAdmin {
+		return nil, common.AuthorizationError{Message: "organization admin required"}
+	}
+
+	return membership, nil
+}
@@ -0,0 +1,410 @@
+package phonebook
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"template/internal/common"
+	"time"
+)
+
+const (
+	AuditAccountExport AuditAction = "account_export"
+	AuditAccountDelete AuditAction = "account_delete"
+)
+
+// AccountExport is the JSON part of a data export: everything stored about
+// a user. Audit events are kept after the account is deleted.
+type AccountExport struct {
+	ExportedAt    time.Time            `json:"exported_at"`
+	Profile       ExportProfile        `json:"profile"`
+	Organizations []ExportOrganization `json:"organizations"`
+	Collections   []ExportCollection   `json:"collections"`
+	Invitations   []ExportInvitation   `json:"invitations"`
+	Tags          []ExportTag          `json:"tags"`
+	Sessions      []ExportSession      `json:"sessions"`
+	Identities    []ExportIdentity     `json:"identities"`
+	AuditEvents   []*AuditEvent        `json:"audit_events"`
+}
+
+type ExportProfile struct {
+	ID          int    `json:"id"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Locale      string `json:"locale"`
+	Timezone    string `json:"timezone"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+type ExportOrganization struct {
+	ID        int             `json:"id"`
+	Name      string          `json:"name"`
+	Role      Role            `json:"role"`
+	JoinedAt  time.Time       `json:"joined_at"`
+	Addresses []ExportAddress `json:"addresses"`
+	Trash     []ExportAddress `json:"trash"`
+}
+
+type ExportAddress struct {
+	ID           int             `json:"id"`
+	Name         string          `json:"name"`
+	PhoneNumber  string          `json:"phone_number"`
+	CollectionID *int            `json:"collection_id,omitempty"`
+	DeletedAt    *time.Time      `json:"deleted_at,omitempty"`
+	Tags         []string        `json:"tags"`
+	Versions     []ExportVersion `json:"versions"`
+}
+
+type ExportVersion struct {
+	Version     int               `json:"version"`
+	ActorID     *int              `json:"actor_id,omitempty"`
+	Action      Action            `json:"action"`
+	Changes     map[string]Change `json:"changes"`
+	Name        string            `json:"name"`
+	PhoneNumber string            `json:"phone_number"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+type ExportCollection struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Owned bool   `json:"owned"`
+}
+
+type ExportInvitation struct {
+	ID           int        `json:"id"`
+	CollectionID int        `json:"collection_id"`
+	Permission   Permission `json:"permission"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type ExportTag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type ExportSession struct {
+	DeviceName string    `json:"device_name"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type ExportIdentity struct {
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Export writes a zip archive of everything stored about the user to w:
+// account.json, and addresses.vcf with the addresses of every organization.
+func (s *AccountService) Export(ctx context.Context, userID int, w io.Writer) error {
+	event := &AuditEvent{ActorID: userID, Action: AuditAccountExport, Target: fmt.Sprintf("user:%d", userID)}
+
+	data, addresses, err := s.collect(ctx, userID)
+
+	err = recordAudit(ctx, s.repo, event, err)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+
+	f, err := archive.Create("account.json")
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(data)
+	if err != nil {
+		return err
+	}
+
+	f, err = archive.Create("addresses.vcf")
+	if err != nil {
+		return err
+	}
+
+	err = WriteVCards(f, addresses)
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func (s *AccountService) collect(ctx context.Context, userID int) (*AccountExport, []*Address, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := &AccountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: ExportProfile{
+			ID:          user.ID,
+			Email:       user.Email,
+			DisplayName: user.DisplayName,
+			Locale:      user.Locale,
+			Timezone:    user.Timezone,
+			AvatarURL:   user.AvatarURL,
+		},
+		Organizations: make([]ExportOrganization, 0),
+		Collections:   make([]ExportCollection, 0),
+		Invitations:   make([]ExportInvitation, 0),
+		Tags:          make([]ExportTag, 0),
+		Sessions:      make([]ExportSession, 0),
+		Identities:    make([]ExportIdentity, 0),
+	}
+	all := make([]*Address, 0)
+
+	memberships, err := s.repo.GetMembershipsByUserID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, membership := range memberships {
+		tenantCtx := common.WithTenantID(ctx, membership.Organization.ID)
+
+		addresses, err := s.repo.GetAddressesByUserID(tenantCtx, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		trash, err := s.repo.GetDeletedAddressesByUserID(tenantCtx, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		organization := ExportOrganization{
+			ID:       membership.Organization.ID,
+			Name:     membership.Organization.Name,
+			Role:     membership.Role,
+			JoinedAt: membership.CreatedAt,
+		}
+
+		organization.Addresses, err = s.exportAddresses(tenantCtx, userID, addresses)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		organization.Trash, err = s.exportAddresses(tenantCtx, userID, trash)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		all = append(all, addresses...)
+		data.Organizations = append(data.Organizations, organization)
+	}
+
+	collections, err := s.repo.GetCollectionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, collection := range collections {
+		data.Collections = append(data.Collections, ExportCollection{
+			ID:    collection.ID,
+			Name:  collection.Name,
+			Owned: collection.Owner.ID == userID,
+		})
+	}
+
+	invitations, err := s.repo.GetPendingSharesByUserID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, share := range invitations {
+		data.Invitations = append(data.Invitations, ExportInvitation{
+			ID:           share.ID,
+			CollectionID: share.Collection.ID,
+			Permission:   share.Permission,
+			CreatedAt:    share.CreatedAt,
+		})
+	}
+
+	tags, err := s.repo.GetTagsByUserID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tag := range tags {
+		data.Tags = append(data.Tags, ExportTag{ID: tag.ID, Name: tag.Name})
+	}
+
+	sessions, err := s.repo.GetSessionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, session := range sessions {
+		data.Sessions = append(data.Sessions, ExportSession{
+			DeviceName: session.DeviceName,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+		})
+	}
+
+	identities, err := s.repo.GetIdentitiesByUserID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, identity := range identities {
+		data.Identities = append(data.Identities, ExportIdentity{
+			Issuer:    identity.Issuer,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+
+	data.AuditEvents, err = s.repo.GetAuditEvents(ctx, &AuditFilter{ActorID: userID})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, all, nil
+}
+
+// exportAddresses adds to each address the user's tags on it and its
+// version history.
+func (s *AccountService) exportAddresses(ctx context.Context, userID int, addresses []*Address) ([]ExportAddress, error) {
+	res := make([]ExportAddress, 0, len(addresses))
+	for _, address := range addresses {
+		item := ExportAddress{
+			ID:          address.ID,
+			Name:        address.Name,
+			PhoneNumber: address.PhoneNumber,
+			DeletedAt:   address.DeletedAt,
+			Tags:        make([]string, 0),
+			Versions:    make([]ExportVersion, 0),
+		}
+		if address.Collection != nil {
+			item.CollectionID = &address.Collection.ID
+		}
+
+		tags, err := s.repo.GetTagsByAddressID(ctx, address.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			if tag.User.ID == userID {
+				item.Tags = append(item.Tags, tag.Name)
+			}
+		}
+
+		versions, err := s.repo.GetAddressVersions(ctx, address.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			exported := ExportVersion{
+				Version:     version.Version,
+				Action:      version.Action,
+				Changes:     version.Changes,
+				Name:        version.Name,
+				PhoneNumber: version.PhoneNumber,
+				CreatedAt:   version.CreatedAt,
+			}
+			if version.Actor != nil {
+				exported.ActorID = &version.Actor.ID
+			}
+
+			item.Versions = append(item.Versions, exported)
+		}
+
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// DeleteAccount removes the user and everything they own once the request
+// is confirmed: with their password, or, for a user without one, with their
+// email address typed into confirm. Organizations the user is the only
+// member of go with them; an organization they are the last admin of but
+// shared with others has to be handed over first. The audit log keeps its
+// entries.
+func (s *AccountService) DeleteAccount(ctx context.Context, userID int, password string, confirm string) error {
+	event := &AuditEvent{ActorID: userID, Action: AuditAccountDelete, Target: fmt.Sprintf("user:%d", userID)}
+
+	err := s.deleteAccount(ctx, userID, password, confirm)
+
+	return recordAudit(ctx, s.repo, event, err)
+}
+
+func (s *AccountService) deleteAccount(ctx context.Context, userID int, password string, confirm string) error {
+	err := s.confirmDeletion(ctx, userID, password, confirm)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.WithinTx(ctx, func(ctx context.Context) error {
+		memberships, err := s.repo.GetMembershipsByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		orphaned := make([]int, 0)
+		for _, membership := range memberships {
+			members, err := s.repo.GetMembershipsByOrganizationID(ctx, membership.Organization.ID)
+			if err != nil {
+				return err
+			}
+
+			if len(members) == 1 {
+				orphaned = append(orphaned, membership.Organization.ID)
+				continue
+			}
+
+			if membership.Role == RoleAdmin && !otherAdmin(members, userID) {
+				return common.ConflictError{Message: fmt.Sprintf("make another member admin of organization %q first", membership.Organization.Name)}
+			}
+		}
+
+		for _, organizationID := range orphaned {
+			err = s.repo.DeleteOrganization(common.WithTenantID(ctx, organizationID), organizationID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.repo.DeleteUser(ctx, userID)
+	})
+}
+
+// confirmDeletion checks the password of a user who has one. Signing in
+// through an identity provider is not confirmation enough for something
+// that cannot be undone, so a user without a password echoes their email.
+func (s *AccountService) confirmDeletion(ctx context.Context, userID int, password string, confirm string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.HasPassword() {
+		return s.checkPassword(ctx, userID, password)
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(confirm), user.Email) {
+		return common.InvariantError{Message: "confirm with your email address"}
+	}
+
+	return nil
+}
+
+func otherAdmin(members []*Membership, userID int) bool {
+	for _, member := range members {
+		if member.User.ID != userID && member.Role == RoleAdmin {
+			return true
+		}
+	}
+
+	return false
+}
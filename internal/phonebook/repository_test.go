@@ -0,0 +1,44 @@
+package phonebook_test
+
+import (
+	"context"
+	"path/filepath"
+	"template/internal/common"
+	"template/internal/db"
+	"template/internal/phonebook"
+	"template/internal/repository"
+	"testing"
+)
+
+func newRepository(t *testing.T) *repository.SQLiteRepository {
+	t.Helper()
+
+	conn, err := db.ConnectSQLite(filepath.Join(t.TempDir(), "phonebook.db"))
+	if err != nil {
+		t.Fatalf("ConnectSQLite: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return repository.NewSQLiteRepository(conn)
+}
+
+// newTenant creates a user with an organization of their own and returns the
+// context their requests run with.
+func newTenant(t *testing.T, repo *repository.SQLiteRepository, email string) (context.Context, int) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	userID, err := repo.NewUser(ctx, &phonebook.User{Email: email, Password: "password"})
+	if err != nil {
+		t.Fatalf("NewUser: %s", err)
+	}
+
+	organization := &phonebook.Organization{Name: email}
+	err = repo.NewOrganization(ctx, organization, &phonebook.User{ID: userID})
+	if err != nil {
+		t.Fatalf("NewOrganization: %s", err)
+	}
+
+	return common.WithActorID(common.WithTenantID(ctx, organization.ID), userID), userID
+}
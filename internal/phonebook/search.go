@@ -0,0 +1,124 @@
+package phonebook
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"template/internal/common"
+	"unicode"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+func (s *AddressService) SearchAddresses(ctx context.Context, userID int, query string, limit int) ([]*Address, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, common.InvariantError{Message: "search query is required"}
+	}
+
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	addresses, err := s.repo.SearchAddresses(ctx, userID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+// Digits strips everything but digits from a phone number so numbers typed
+// with different punctuation compare equal.
+func Digits(phoneNumber string) string {
+	var b strings.Builder
+	for _, r := range phoneNumber {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// MatchAddresses is the search used by repositories without full-text support.
+// It ranks exact name matches first, then names containing the query, then
+// names where every query word prefixes a name word, and finally phone
+// numbers containing the query digits.
+func MatchAddresses(addresses []*Address, query string, limit int) []*Address {
+	type match struct {
+		address *Address
+		score   int
+	}
+
+	query = strings.ToLower(strings.TrimSpace(query))
+	words := strings.FieldsFunc(query, isSeparator)
+	digits := Digits(query)
+
+	matches := make([]match, 0)
+	for _, address := range addresses {
+		name := strings.ToLower(address.Name)
+		score := 0
+
+		switch {
+		case name == query:
+			score = 4
+		case strings.Contains(name, query):
+			score = 3
+		case len(words) > 0 && prefixesAll(strings.FieldsFunc(name, isSeparator), words):
+			score = 2
+		case len(digits) > 0 && strings.Contains(Digits(address.PhoneNumber), digits):
+			score = 1
+		}
+
+		if score > 0 {
+			matches = append(matches, match{address, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].address.ID < matches[j].address.ID
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	res := make([]*Address, 0, len(matches))
+	for _, m := range matches {
+		res = append(res, m.address)
+	}
+
+	return res
+}
+
+func prefixesAll(nameWords []string, queryWords []string) bool {
+	for _, qw := range queryWords {
+		found := false
+		for _, nw := range nameWords {
+			if strings.HasPrefix(nw, qw) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
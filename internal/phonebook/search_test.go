@@ -0,0 +1,105 @@
+package phonebook_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"template/internal/repository"
+	"testing"
+)
+
+func names(addresses []*phonebook.Address) string {
+	res := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		res = append(res, address.Name)
+	}
+
+	return fmt.Sprint(res)
+}
+
+func TestMatchAddresses(t *testing.T) {
+	addresses := []*phonebook.Address{
+		{ID: 1, Name: "Ann Marie Lee", PhoneNumber: "+1 555 0100"},
+		{ID: 2, Name: "Joanne", PhoneNumber: "+1 555 0101"},
+		{ID: 3, Name: "Ann", PhoneNumber: "+1 555 0102"},
+		{ID: 4, Name: "Bob", PhoneNumber: "(030) 123-4567"},
+		{ID: 5, Name: "Anna-Lena", PhoneNumber: "+1 555 0103"},
+		{ID: 6, Name: "Office 0101", PhoneNumber: "+1 555 0199"},
+		{ID: 7, Name: "Susann Lee", PhoneNumber: "+1 555 0104"},
+		{ID: 8, Name: "Ann Lee", PhoneNumber: "+1 555 0105"},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  string
+	}{
+		{"exact, then contains, then word prefixes", "ann lee", 0, "[Ann Lee Susann Lee Ann Marie Lee]"},
+		{"ties by ID", "ann", 0, "[Ann Ann Marie Lee Joanne Anna-Lena Susann Lee Ann Lee]"},
+		{"case and space insensitive", "  ANN ", 0, "[Ann Ann Marie Lee Joanne Anna-Lena Susann Lee Ann Lee]"},
+		{"word prefixes in any order", "lee ann", 0, "[Ann Marie Lee Ann Lee]"},
+		{"word prefixes across separators", "lena ann", 0, "[Anna-Lena]"},
+		{"digits ignore punctuation", "0301234", 0, "[Bob]"},
+		{"digits ignore query punctuation", "123-45", 0, "[Bob]"},
+		{"digits match after names", "0101", 0, "[Office 0101 Joanne]"},
+		{"limit", "ann", 2, "[Ann Ann Marie Lee]"},
+		{"no match", "carol", 0, "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := names(phonebook.MatchAddresses(addresses, tt.query, tt.limit))
+			if got != tt.want {
+				t.Fatalf("MatchAddresses(%q, %d) = %s, want %s", tt.query, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+// limitRepository records the limit searches reach the repository with.
+type limitRepository struct {
+	*repository.SQLiteRepository
+	limit int
+}
+
+func (r *limitRepository) SearchAddresses(ctx context.Context, userID int, query string, limit int) ([]*phonebook.Address, error) {
+	r.limit = limit
+	return r.SQLiteRepository.SearchAddresses(ctx, userID, query, limit)
+}
+
+func TestSearchAddressesLimit(t *testing.T) {
+	repo := &limitRepository{SQLiteRepository: newRepository(t)}
+	svc := phonebook.NewAddressService(repo, phonebook.DefaultMaxBatchSize)
+
+	ctx, userID := newTenant(t, repo.SQLiteRepository, "alice@example.com")
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{-1, phonebook.DefaultSearchLimit},
+		{0, phonebook.DefaultSearchLimit},
+		{5, 5},
+		{phonebook.MaxSearchLimit, phonebook.MaxSearchLimit},
+		{phonebook.MaxSearchLimit + 1, phonebook.MaxSearchLimit},
+	}
+
+	for _, tt := range tests {
+		_, err := svc.SearchAddresses(ctx, userID, "ann", tt.limit)
+		if err != nil {
+			t.Fatalf("SearchAddresses: %s", err)
+		}
+
+		if repo.limit != tt.want {
+			t.Errorf("SearchAddresses with limit %d searched with %d, want %d", tt.limit, repo.limit, tt.want)
+		}
+	}
+
+	_, err := svc.SearchAddresses(ctx, userID, "  ", 0)
+	if !errors.As(err, &common.InvariantError{}) {
+		t.Fatalf("SearchAddresses of a blank query = %v, want an invariant error", err)
+	}
+}
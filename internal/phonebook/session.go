@@ -0,0 +1,178 @@
+package phonebook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"template/internal/common"
+	"time"
+)
+
+// SessionTouchInterval limits how often a session's last-seen time is
+// written, so authenticated reads do not each cost a write.
+var SessionTouchInterval = time.Minute
+
+// Session is a device signed in to an account. Every token carries the ID
+// of the session it was issued for and stops working once the session is
+// revoked.
+type Session struct {
+	ID         string
+	UserID     int
+	DeviceName string
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+type SessionIssuer interface {
+	NewSession(context.Context, *Session) error
+	// ExtendSession moves the expiry of an unrevoked session.
+	ExtendSession(ctx context.Context, ID string, expiresAt time.Time) error
+}
+
+type SessionRepository interface {
+	SessionIssuer
+	GetSession(ctx context.Context, ID string) (*Session, error)
+	// GetSessionsByUserID lists the user's sessions that are neither revoked
+	// nor expired, most recently seen first.
+	GetSessionsByUserID(context.Context, int) ([]*Session, error)
+	TouchSession(ctx context.Context, ID string, lastSeenAt time.Time) error
+	RevokeSession(ctx context.Context, userID int, ID string) error
+	RevokeOtherSessions(ctx context.Context, userID int, keepID string) (int, error)
+	GetMembership(ctx context.Context, organizationID int, userID int) (*Membership, error)
+	// PurgeSessions removes sessions that expired or were revoked before the
+	// given time.
+	PurgeSessions(ctx context.Context, before time.Time) (int, error)
+}
+
+// issueToken signs a token for userID in organizationID. It continues the
+// session ctx was authenticated with, or starts one for device.
+func issueToken(ctx context.Context, repo SessionIssuer, userID int, organizationID int, device string) (string, error) {
+	expiresAt := time.Now().Add(common.JwtLifetime)
+
+	sessionID, ok := common.SessionID(ctx)
+	if ok {
+		err := repo.ExtendSession(ctx, sessionID, expiresAt)
+		if err != nil {
+			return "", err
+		}
+
+		return common.JwtGenerate(userID, organizationID, sessionID)
+	}
+
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	client := common.ClientFrom(ctx)
+	if device == "" {
+		device = client.UserAgent
+	}
+
+	session := &Session{
+		ID:         hex.EncodeToString(id),
+		UserID:     userID,
+		DeviceName: device,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		ExpiresAt:  expiresAt,
+	}
+
+	err = repo.NewSession(ctx, session)
+	if err != nil {
+		return "", err
+	}
+
+	return common.JwtGenerate(userID, organizationID, session.ID)
+}
+
+type SessionService struct {
+	repo SessionRepository
+}
+
+func NewSessionService(repo SessionRepository) *SessionService {
+	return &SessionService{repo}
+}
+
+// Authenticate checks that a token's session is still live and that the
+// user is still a member of the token's organization, and records that the
+// session was seen.
+func (s *SessionService) Authenticate(ctx context.Context, userID int, organizationID int, sessionID string) error {
+	session, err := s.repo.GetSession(ctx, sessionID)
+	if errors.As(err, &common.NotFoundError{}) {
+		return common.AuthenticationError{Message: "session not found"}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if session.UserID != userID || session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+		return common.AuthenticationError{Message: "session revoked"}
+	}
+
+	_, err = s.repo.GetMembership(ctx, organizationID, userID)
+	if errors.As(err, &common.NotFoundError{}) {
+		return common.AuthenticationError{Message: "no longer a member of the organization"}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if now.Sub(session.LastSeenAt) < SessionTouchInterval {
+		return nil
+	}
+
+	err = s.repo.TouchSession(ctx, sessionID, now)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SessionService) Sessions(ctx context.Context, userID int) ([]*Session, error) {
+	sessions, err := s.repo.GetSessionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *SessionService) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	err := s.repo.RevokeSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions signs the user out everywhere but the session ctx was
+// authenticated with, and reports how many sessions were revoked.
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, userID int) (int, error) {
+	sessionID, ok := common.SessionID(ctx)
+	if !ok {
+		return 0, common.InvariantError{Message: "request is not authenticated with a session"}
+	}
+
+	n, err := s.repo.RevokeOtherSessions(ctx, userID, sessionID)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (s *SessionService) PurgeSessions(ctx context.Context, retention time.Duration) (int, error) {
+	return s.repo.PurgeSessions(ctx, time.Now().Add(-retention))
+}
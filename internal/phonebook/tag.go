@@ -0,0 +1,176 @@
+package phonebook
+
+import (
+	"context"
+	"strings"
+	"template/internal/common"
+)
+
+type Tag struct {
+	ID   int
+	User *User
+	Name string
+}
+
+type TagRepository interface {
+	NewTag(context.Context, *Tag) error
+	GetTagsByUserID(context.Context, int) ([]*Tag, error)
+	GetTagByID(context.Context, int) (*Tag, error)
+	UpdateTag(context.Context, int, *Tag) error
+	DeleteTag(context.Context, int) error
+	GetAddressByID(context.Context, int) (*Address, error)
+	TagAddresses(ctx context.Context, tagIDs []int, addressIDs []int) error
+	UntagAddresses(ctx context.Context, tagIDs []int, addressIDs []int) error
+}
+
+type TagService struct {
+	repo TagRepository
+}
+
+func NewTagService(repo TagRepository) *TagService {
+	return &TagService{repo}
+}
+
+func (s *TagService) NewTag(ctx context.Context, userID int, tag *Tag) error {
+	tag.User = &User{ID: userID}
+	tag.Name = strings.TrimSpace(tag.Name)
+
+	err := checkName(tag.Name)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.NewTag(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TagService) GetTagsByUserID(ctx context.Context, userID int) ([]*Tag, error) {
+	tags, err := s.repo.GetTagsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (s *TagService) GetTagByID(ctx context.Context, userID int, tagID int) (*Tag, error) {
+	return s.ownedTag(ctx, userID, tagID, "unauthorized read")
+}
+
+func (s *TagService) UpdateTag(ctx context.Context, userID int, tagID int, newTag *Tag) error {
+	_, err := s.ownedTag(ctx, userID, tagID, "unauthorized update")
+	if err != nil {
+		return err
+	}
+
+	newTag.Name = strings.TrimSpace(newTag.Name)
+
+	err = checkName(newTag.Name)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.UpdateTag(ctx, tagID, newTag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TagService) DeleteTag(ctx context.Context, userID int, tagID int) error {
+	_, err := s.ownedTag(ctx, userID, tagID, "unauthorized delete")
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteTag(ctx, tagID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TagService) TagAddresses(ctx context.Context, userID int, tagIDs []int, addressIDs []int) error {
+	err := s.checkAssignment(ctx, userID, tagIDs, addressIDs)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.TagAddresses(ctx, tagIDs, addressIDs)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *TagService) UntagAddresses(ctx context.Context, userID int, tagIDs []int, addressIDs []int) error {
+	err := s.checkAssignment(ctx, userID, tagIDs, addressIDs)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.UntagAddresses(ctx, tagIDs, addressIDs)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkName validates a tag name. Names unique per user are enforced by the
+// repository, which reports a taken one as a common.ConflictError.
+func checkName(name string) error {
+	if name == "" {
+		return common.InvariantError{Message: "tag name is required"}
+	}
+
+	return nil
+}
+
+// checkAssignment applies the ownership rule of AddressService.UpdateAddress
+// to every address and tag involved in a tag or untag.
+func (s *TagService) checkAssignment(ctx context.Context, userID int, tagIDs []int, addressIDs []int) error {
+	if len(tagIDs) == 0 || len(addressIDs) == 0 {
+		return common.InvariantError{Message: "tags and addresses are required"}
+	}
+
+	for _, tagID := range tagIDs {
+		_, err := s.ownedTag(ctx, userID, tagID, "unauthorized update")
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, addressID := range addressIDs {
+		address, err := s.repo.GetAddressByID(ctx, addressID)
+		if err != nil {
+			return err
+		}
+
+		if address.User.ID != userID {
+			return common.AuthorizationError{Message: "unauthorized update"}
+		}
+	}
+
+	return nil
+}
+
+func (s *TagService) ownedTag(ctx context.Context, userID int, tagID int, message string) (*Tag, error) {
+	tag, err := s.repo.GetTagByID(ctx, tagID)
+	if err != nil {
+		return nil, err
+	}
+
+	if tag.User.ID != userID {
+		return nil, common.AuthorizationError{Message: message}
+	}
+
+	return tag, nil
+}
@@ -0,0 +1,11 @@
+package phonebook
+
+import "context"
+
+// Transactor runs a unit of work. Repository calls made with the context
+// handed to fn share one transaction, which commits when fn returns nil and
+// rolls back otherwise. A context that already carries a transaction joins
+// it instead of starting another.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -2,18 +2,39 @@ package phonebook
 
 import (
 	"context"
+	"errors"
 	"template/internal/common"
 )
 
 type User struct {
-	ID       int
-	Email    string
-	Password string
+	ID    int
+	Email string
+	// Password is the bcrypt hash of the user's password, or empty for a
+	// user who signs in only through an identity provider.
+	Password    string
+	DisplayName string
+	Locale      string
+	Timezone    string
+	// AvatarURL references an image hosted elsewhere; avatars are not
+	// stored here.
+	AvatarURL string
+}
+
+// HasPassword reports whether the user can sign in with a password.
+func (u *User) HasPassword() bool {
+	return u.Password != ""
 }
 
 type UserRepository interface {
+	Transactor
+	AuditRecorder
+	SessionIssuer
 	NewUser(context.Context, *User) (int, error)
 	GetUserByEmail(context.Context, string) (*User, error)
+	GetUserByIdentity(ctx context.Context, issuer string, subject string) (*User, error)
+	NewIdentity(ctx context.Context, userID int, identity *Identity) error
+	NewOrganization(ctx context.Context, organization *Organization, admin *User) error
+	GetMembershipsByUserID(context.Context, int) ([]*Membership, error)
 }
 
 type UserService struct {
@@ -24,16 +45,18 @@ func NewUserService(repo UserRepository) *UserService {
 	return &UserService{repo}
 }
 
-func (s *UserService) Register(ctx context.Context, user *User) (string, error) {
-	currentUser, err := s.repo.GetUserByEmail(ctx, user.Email)
-	if err != nil {
-		return "", err
-	}
+// Register creates the user and signs them in on device, which names the
+// new session; it defaults to the client's user agent.
+func (s *UserService) Register(ctx context.Context, user *User, device string) (string, error) {
+	event := &AuditEvent{Action: AuditRegister, Target: "user:" + user.Email}
 
-	if currentUser != nil {
-		return "", common.InvariantError{Message: "email already registered"}
-	}
+	token, err := s.register(ctx, user, device, event)
+
+	return token, recordAudit(ctx, s.repo, event, err)
+}
 
+func (s *UserService) register(ctx context.Context, user *User, device string, event *AuditEvent) (string, error) {
+	var err error
 	user.Password, err = common.BcryptHash(user.Password)
 	if err != nil {
 		return "", err
@@ -43,8 +66,15 @@ func (s *UserService) Register(ctx context.Context, user *User) (string, error)
 	if err != nil {
 		return "", err
 	}
+	event.ActorID = id
 
-	token, err := common.JwtGenerate(id)
+	organizationID, err := s.defaultOrganization(ctx, &User{ID: id, Email: user.Email})
+	if err != nil {
+		return "", err
+	}
+	event.OrganizationID = organizationID
+
+	token, err := issueToken(ctx, s.repo, id, organizationID, device)
 	if err != nil {
 		return "", err
 	}
@@ -52,13 +82,26 @@ func (s *UserService) Register(ctx context.Context, user *User) (string, error)
 	return token, nil
 }
 
-func (s *UserService) Login(ctx context.Context, loginUser *User) (string, error) {
+func (s *UserService) Login(ctx context.Context, loginUser *User, device string) (string, error) {
+	event := &AuditEvent{Action: AuditLogin, Target: "user:" + loginUser.Email}
+
+	token, err := s.login(ctx, loginUser, device, event)
+
+	return token, recordAudit(ctx, s.repo, event, err)
+}
+
+func (s *UserService) login(ctx context.Context, loginUser *User, device string, event *AuditEvent) (string, error) {
 	user, err := s.repo.GetUserByEmail(ctx, loginUser.Email)
+	if errors.As(err, &common.NotFoundError{}) {
+		return "", common.InvariantError{Message: "incorrect email or password"}
+	}
+
 	if err != nil {
 		return "", err
 	}
+	event.ActorID = user.ID
 
-	if user == nil {
+	if !user.HasPassword() {
 		return "", common.InvariantError{Message: "incorrect email or password"}
 	}
 
@@ -70,11 +113,37 @@ func (s *UserService) Login(ctx context.Context, loginUser *User) (string, error
 		return "", common.InvariantError{Message: "incorrect email or password"}
 	}
 
-	token, err := common.JwtGenerate(user.ID)
+	organizationID, err := s.defaultOrganization(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	event.OrganizationID = organizationID
+
+	token, err := issueToken(ctx, s.repo, user.ID, organizationID, device)
 	if err != nil {
 		return "", err
 	}
 
 	return token, nil
+}
+
+// defaultOrganization is the tenant a fresh token is issued for: the user's
+// oldest membership, or a new personal organization for users without one.
+func (s *UserService) defaultOrganization(ctx context.Context, user *User) (int, error) {
+	memberships, err := s.repo.GetMembershipsByUserID(ctx, user.ID)
+	if err != nil {
+		return -1, err
+	}
+
+	if len(memberships) > 0 {
+		return memberships[0].Organization.ID, nil
+	}
+
+	organization := &Organization{Name: user.Email}
+	err = s.repo.NewOrganization(ctx, organization, user)
+	if err != nil {
+		return -1, err
+	}
 
+	return organization.ID, nil
 }
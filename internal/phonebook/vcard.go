@@ -0,0 +1,61 @@
+package phonebook
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+var vcardEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `;`, `\;`, "\r\n", `\n`, "\n", `\n`)
+
+// WriteVCards writes addresses to w as vCard 3.0 (RFC 2426) cards.
+func WriteVCards(w io.Writer, addresses []*Address) error {
+	buf := bufio.NewWriter(w)
+
+	for _, address := range addresses {
+		name := vcardEscaper.Replace(address.Name)
+
+		lines := []string{
+			"BEGIN:VCARD",
+			"VERSION:3.0",
+			"FN:" + name,
+			"N:" + name + ";;;;",
+			"TEL;TYPE=VOICE:" + vcardEscaper.Replace(address.PhoneNumber),
+			"END:VCARD",
+		}
+
+		for _, line := range lines {
+			_, err := buf.WriteString(foldLine(line))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return buf.Flush()
+}
+
+// foldLine ends line with CRLF, breaking it so that no line is longer than
+// 75 octets. Continuation lines start with a space.
+func foldLine(line string) string {
+	var b strings.Builder
+	limit := 75
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+
+	b.WriteString(line)
+	b.WriteString("\r\n")
+
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (r *store) DeleteOrganization(ctx context.Context, ID int) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		return deleteOrganization(ctx, tx, ID)
+	})
+}
+
+func (r *store) DeleteUser(ctx context.Context, ID int) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		return deleteUser(ctx, tx, ID)
+	})
+}
+
+// deleteOrganization and deleteUser rely on the schema's foreign keys:
+// deleting the row cascades to everything that belongs to it, and sets
+// references to it that outlive it, like the collection of an address or
+// the actor of a version, to NULL.
+func deleteOrganization(ctx context.Context, tx *sql.Tx, ID int) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM organizations WHERE id = $1`, ID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "organization not found")
+}
+
+func deleteUser(ctx context.Context, tx *sql.Tx, ID int) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, ID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "user not found")
+}
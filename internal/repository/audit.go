@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"template/internal/phonebook"
+	"time"
+)
+
+// NewAuditEvent appends event to the audit log. The tables reject updates
+// and deletes, so rows written here are final.
+func (r *store) NewAuditEvent(ctx context.Context, event *phonebook.AuditEvent) error {
+	event.CreatedAt = time.Now().UTC()
+
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO audit_events (organization_id, actor_id, action, target, ip, user_agent, outcome, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		nullInt(event.OrganizationID),
+		nullInt(event.ActorID),
+		event.Action,
+		event.Target,
+		event.IP,
+		event.UserAgent,
+		event.Outcome,
+		event.Reason,
+		event.CreatedAt,
+	).Scan(&event.ID)
+
+	if err != nil {
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (r *store) GetAuditEvents(ctx context.Context, filter *phonebook.AuditFilter) ([]*phonebook.AuditEvent, error) {
+	where := make([]string, 0)
+	args := make([]any, 0)
+
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.OrganizationID > 0 {
+		add("organization_id = $%d", filter.OrganizationID)
+	}
+	if filter.ActorID > 0 {
+		add("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.Outcome != "" {
+		add("outcome = $%d", filter.Outcome)
+	}
+	if !filter.Since.IsZero() {
+		add("created_at >= $%d", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		add("created_at < $%d", filter.Until.UTC())
+	}
+	if filter.BeforeID > 0 {
+		add("id < $%d", filter.BeforeID)
+	}
+
+	query := `SELECT id, organization_id, actor_id, action, target, ip, user_agent, outcome, reason, created_at FROM audit_events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*phonebook.AuditEvent, 0)
+	for rows.Next() {
+		var event phonebook.AuditEvent
+		var organizationID, actorID sql.NullInt64
+
+		err = rows.Scan(
+			&event.ID,
+			&organizationID,
+			&actorID,
+			&event.Action,
+			&event.Target,
+			&event.IP,
+			&event.UserAgent,
+			&event.Outcome,
+			&event.Reason,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		event.OrganizationID = int(organizationID.Int64)
+		event.ActorID = int(actorID.Int64)
+		res = append(res, &event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n > 0}
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"testing"
+	"time"
+)
+
+// benchSeed is how many addresses the read benchmarks have to choose from.
+const benchSeed = 100
+
+// benchBackends are the repositories every benchmark runs against. The
+// Postgres ones share a database so the database/sql and pgxpool results
+// compare, and are skipped without TEST_DATABASE_URL.
+var benchBackends = []struct {
+	name string
+	new  func(b *testing.B) Repository
+}{
+	{"sqlite", func(b *testing.B) Repository { return newSQLiteRepository(b) }},
+	{"pgx", func(b *testing.B) Repository { return newPostgreSQLRepository(b) }},
+	{"pgxpool", func(b *testing.B) Repository { return newPgxPoolRepository(b) }},
+}
+
+// runBenchmark runs bench against each backend, as a user in their own
+// organization holding benchSeed addresses.
+func runBenchmark(b *testing.B, bench func(b *testing.B, ctx context.Context, repo Repository, userID int)) {
+	for _, backend := range benchBackends {
+		backend := backend
+		b.Run(backend.name, func(b *testing.B) {
+			repo := backend.new(b)
+			ctx := context.Background()
+
+			user := &phonebook.User{Email: fmt.Sprintf("bench-%d@example.invalid", time.Now().UnixNano()), Password: "-"}
+			userID, err := repo.NewUser(ctx, user)
+			if err != nil {
+				b.Fatalf("NewUser: %s", err)
+			}
+			user.ID = userID
+
+			organization := &phonebook.Organization{Name: "bench"}
+			err = repo.NewOrganization(ctx, organization, user)
+			if err != nil {
+				b.Fatalf("NewOrganization: %s", err)
+			}
+
+			ctx = common.WithActorID(common.WithTenantID(ctx, organization.ID), userID)
+
+			addresses := make([]*phonebook.Address, 0, benchSeed)
+			for i := 0; i < benchSeed; i++ {
+				addresses = append(addresses, &phonebook.Address{
+					User:        &phonebook.User{ID: userID},
+					Name:        fmt.Sprintf("Bench %d", i),
+					PhoneNumber: fmt.Sprintf("+1 555 %07d", i),
+				})
+			}
+
+			err = repo.NewAddresses(ctx, addresses)
+			if err != nil {
+				b.Fatalf("NewAddresses: %s", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			bench(b, ctx, repo, userID)
+		})
+	}
+}
+
+func BenchmarkNewAddress(b *testing.B) { runBenchmark(b, benchNewAddress) }
+
+func BenchmarkGetAddressByID(b *testing.B) { runBenchmark(b, benchGetAddressByID) }
+
+func BenchmarkGetAddressesByUserID(b *testing.B) { runBenchmark(b, benchGetAddressesByUserID) }
+
+func BenchmarkUpdateAddress(b *testing.B) { runBenchmark(b, benchUpdateAddress) }
+
+func benchNewAddress(b *testing.B, ctx context.Context, repo Repository, userID int) {
+	for i := 0; i < b.N; i++ {
+		err := repo.NewAddress(ctx, &phonebook.Address{
+			User:        &phonebook.User{ID: userID},
+			Name:        fmt.Sprintf("New %d", i),
+			PhoneNumber: "+1 555 0000000",
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchGetAddressByID(b *testing.B, ctx context.Context, repo Repository, userID int) {
+	addresses, err := repo.GetAddressesByUserID(ctx, userID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(addresses) == 0 {
+		b.Fatal("no seeded addresses")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := repo.GetAddressByID(ctx, addresses[i%len(addresses)].ID)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchGetAddressesByUserID(b *testing.B, ctx context.Context, repo Repository, userID int) {
+	for i := 0; i < b.N; i++ {
+		_, err := repo.GetAddressesByUserID(ctx, userID)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchUpdateAddress(b *testing.B, ctx context.Context, repo Repository, userID int) {
+	addresses, err := repo.GetAddressesByUserID(ctx, userID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(addresses) == 0 {
+		b.Fatal("no seeded addresses")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		address := addresses[i%len(addresses)]
+		err := repo.UpdateAddress(ctx, address.ID, &phonebook.Address{
+			Name:        address.Name,
+			PhoneNumber: fmt.Sprintf("+1 555 %07d", i),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"time"
+)
+
+// Cache stores encoded values for the CachedRepository. cache.LRU keeps them
+// in process; a remote cache lets several servers share them. Failures are
+// counted and logged, and the read falls through to the repository.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+// CachedRepository reads addresses by ID through a cache, filled from the
+// primary. Writes invalidate the addresses they touch, again after their
+// unit of work ends; a fill that overlaps an invalidation is taken back, as
+// the row it read may predate the write. Reads inside a unit of work bypass
+// the cache. Entries live for at most ttl, which bounds how long a write on
+// another server can leave a shared cache stale.
+type CachedRepository struct {
+	Repository
+	cache  Cache
+	ttl    time.Duration
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+	// generation counts invalidations, bumped before their deletes.
+	generation atomic.Uint64
+}
+
+func NewCachedRepository(repo Repository, cache Cache, ttl time.Duration) *CachedRepository {
+	return &CachedRepository{Repository: repo, cache: cache, ttl: ttl}
+}
+
+type pendingKey struct{}
+
+// pending collects the keys written by a unit of work.
+type pending struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+type cachedAddress struct {
+	ID           int    `json:"id"`
+	UserID       int    `json:"user_id"`
+	CollectionID *int   `json:"collection_id,omitempty"`
+	Name         string `json:"name"`
+	PhoneNumber  string `json:"phone_number"`
+	Version      int    `json:"version"`
+}
+
+func addressKey(tenantID int, ID int) string {
+	return fmt.Sprintf("address:%d:%d", tenantID, ID)
+}
+
+func (r *CachedRepository) Stats() CacheStats {
+	return CacheStats{
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+		Errors: r.errors.Load(),
+	}
+}
+
+func (r *CachedRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		return r.Repository.WithinTx(ctx, fn)
+	}
+
+	p := &pending{}
+	err := r.Repository.WithinTx(context.WithValue(ctx, pendingKey{}, p), fn)
+
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+
+	r.delete(ctx, keys...)
+
+	return err
+}
+
+func (r *CachedRepository) GetAddressByID(ctx context.Context, ID int) (*phonebook.Address, error) {
+	tenantID, ok := common.TenantID(ctx)
+	if _, inTx := ctx.Value(pendingKey{}).(*pending); !ok || inTx {
+		return r.Repository.GetAddressByID(ctx, ID)
+	}
+
+	key := addressKey(tenantID, ID)
+
+	value, found, err := r.cache.Get(ctx, key)
+	if err != nil {
+		r.fail("get", key, err)
+	}
+
+	if found {
+		var cached cachedAddress
+		err = json.Unmarshal(value, &cached)
+		if err == nil {
+			r.hits.Add(1)
+			return cached.address(), nil
+		}
+
+		r.fail("decode", key, err)
+	}
+
+	r.misses.Add(1)
+
+	generation := r.generation.Load()
+
+	address, err := r.Repository.GetAddressByID(common.WithPrimary(ctx), ID)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err = json.Marshal(newCachedAddress(address))
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.cache.Set(ctx, key, value, r.ttl)
+	if err != nil {
+		r.fail("set", key, err)
+	}
+
+	// Take the fill back if an invalidation started meanwhile, as the row
+	// may predate its write. One starting after this check deletes the
+	// entry itself.
+	if r.generation.Load() != generation {
+		err = r.cache.Delete(ctx, key)
+		if err != nil {
+			r.fail("delete", key, err)
+		}
+	}
+
+	return address, nil
+}
+
+func (r *CachedRepository) UpdateAddress(ctx context.Context, ID int, address *phonebook.Address) error {
+	defer r.invalidate(ctx, ID)
+	return r.Repository.UpdateAddress(ctx, ID, address)
+}
+
+func (r *CachedRepository) DeleteAddress(ctx context.Context, ID int, version int) error {
+	defer r.invalidate(ctx, ID)
+	return r.Repository.DeleteAddress(ctx, ID, version)
+}
+
+func (r *CachedRepository) RestoreAddress(ctx context.Context, ID int) error {
+	defer r.invalidate(ctx, ID)
+	return r.Repository.RestoreAddress(ctx, ID)
+}
+
+func (r *CachedRepository) RevertAddress(ctx context.Context, ID int, to *phonebook.AddressVersion) error {
+	defer r.invalidate(ctx, ID)
+	return r.Repository.RevertAddress(ctx, ID, to)
+}
+
+func (r *CachedRepository) MergeAddresses(ctx context.Context, merge *phonebook.Merge) error {
+	defer r.invalidate(ctx, mergedIDs(merge)...)
+	return r.Repository.MergeAddresses(ctx, merge)
+}
+
+func (r *CachedRepository) UndoMerge(ctx context.Context, merge *phonebook.Merge) error {
+	defer r.invalidate(ctx, mergedIDs(merge)...)
+	return r.Repository.UndoMerge(ctx, merge)
+}
+
+func (r *CachedRepository) SetAddressCollection(ctx context.Context, addressID int, collectionID *int) error {
+	defer r.invalidate(ctx, addressID)
+	return r.Repository.SetAddressCollection(ctx, addressID, collectionID)
+}
+
+// DeleteCollection invalidates the addresses of the collection, which lose
+// it along with the permissions it granted.
+func (r *CachedRepository) DeleteCollection(ctx context.Context, ID int) error {
+	addresses, err := r.Repository.GetAddressesByCollectionID(ctx, ID)
+	if err != nil {
+		return err
+	}
+
+	defer r.invalidate(ctx, addressIDs(addresses)...)
+	return r.Repository.DeleteCollection(ctx, ID)
+}
+
+// invalidate drops the addresses from the cache now and, inside a unit of
+// work, once more after it ends.
+func (r *CachedRepository) invalidate(ctx context.Context, IDs ...int) {
+	tenantID, ok := common.TenantID(ctx)
+	if !ok || len(IDs) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(IDs))
+	for _, ID := range IDs {
+		keys = append(keys, addressKey(tenantID, ID))
+	}
+
+	r.delete(ctx, keys...)
+
+	if p, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		p.mu.Lock()
+		p.keys = append(p.keys, keys...)
+		p.mu.Unlock()
+	}
+}
+
+func (r *CachedRepository) delete(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	r.generation.Add(1)
+
+	err := r.cache.Delete(ctx, keys...)
+	if err != nil {
+		r.fail("delete", keys[0], err)
+	}
+}
+
+func (r *CachedRepository) fail(op string, key string, err error) {
+	r.errors.Add(1)
+	common.Log.Warnf("cache %s %s: %s", op, key, err)
+}
+
+func mergedIDs(merge *phonebook.Merge) []int {
+	IDs := make([]int, 0, len(merge.Merged)+1)
+	if merge.Survivor != nil {
+		IDs = append(IDs, merge.Survivor.ID)
+	}
+
+	for _, address := range merge.Merged {
+		IDs = append(IDs, address.ID)
+	}
+
+	return IDs
+}
+
+func newCachedAddress(address *phonebook.Address) cachedAddress {
+	res := cachedAddress{
+		ID:          address.ID,
+		UserID:      address.User.ID,
+		Name:        address.Name,
+		PhoneNumber: address.PhoneNumber,
+		Version:     address.Version,
+	}
+
+	if address.Collection != nil {
+		res.CollectionID = &address.Collection.ID
+	}
+
+	return res
+}
+
+func (c cachedAddress) address() *phonebook.Address {
+	res := &phonebook.Address{
+		ID:          c.ID,
+		User:        &phonebook.User{ID: c.UserID},
+		Name:        c.Name,
+		PhoneNumber: c.PhoneNumber,
+		Version:     c.Version,
+	}
+
+	if c.CollectionID != nil {
+		res.Collection = &phonebook.Collection{ID: *c.CollectionID}
+	}
+
+	return res
+}
+
+// DeleteOrganization invalidates every address of the organization.
+func (r *CachedRepository) DeleteOrganization(ctx context.Context, ID int) error {
+	tenantCtx := common.WithTenantID(ctx, ID)
+
+	addresses, err := r.Repository.Addresses(tenantCtx)
+	if err != nil {
+		return err
+	}
+
+	defer r.invalidate(tenantCtx, addressIDs(addresses)...)
+	return r.Repository.DeleteOrganization(ctx, ID)
+}
+
+// DeleteUser invalidates the user's addresses in the organizations they
+// are a member of.
+func (r *CachedRepository) DeleteUser(ctx context.Context, ID int) error {
+	memberships, err := r.Repository.GetMembershipsByUserID(ctx, ID)
+	if err != nil {
+		return err
+	}
+
+	owned := make(map[int][]int, len(memberships))
+	for _, membership := range memberships {
+		tenantCtx := common.WithTenantID(ctx, membership.Organization.ID)
+
+		addresses, err := r.Repository.GetAddressesByUserID(tenantCtx, ID)
+		if err != nil {
+			return err
+		}
+
+		owned[membership.Organization.ID] = addressIDs(addresses)
+	}
+
+	defer func() {
+		for tenantID, IDs := range owned {
+			r.invalidate(common.WithTenantID(ctx, tenantID), IDs...)
+		}
+	}()
+
+	return r.Repository.DeleteUser(ctx, ID)
+}
+
+func addressIDs(addresses []*phonebook.Address) []int {
+	IDs := make([]int, 0, len(addresses))
+	for _, address := range addresses {
+		IDs = append(IDs, address.ID)
+	}
+
+	return IDs
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"template/internal/cache"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"testing"
+	"time"
+)
+
+// racingRepository runs write after reading an address, as if the write
+// committed between a cache miss's read and its fill.
+type racingRepository struct {
+	*SQLiteRepository
+	write   func()
+	primary bool
+}
+
+func (r *racingRepository) GetAddressByID(ctx context.Context, ID int) (*phonebook.Address, error) {
+	r.primary = common.Primary(ctx)
+
+	address, err := r.SQLiteRepository.GetAddressByID(ctx, ID)
+	if r.write != nil {
+		write := r.write
+		r.write = nil
+		write()
+	}
+
+	return address, err
+}
+
+func TestCachedRepositoryFillRace(t *testing.T) {
+	common.SetLogger(common.NewLogrusLogger())
+
+	backend := &racingRepository{SQLiteRepository: newSQLiteRepository(t)}
+	repo := NewCachedRepository(backend, cache.NewLRU(10), time.Minute)
+
+	ctx := context.Background()
+
+	userID, err := repo.NewUser(ctx, &phonebook.User{Email: "alice@example.com", Password: "password"})
+	if err != nil {
+		t.Fatalf("NewUser: %s", err)
+	}
+
+	organization := &phonebook.Organization{Name: "alice"}
+	err = repo.NewOrganization(ctx, organization, &phonebook.User{ID: userID})
+	if err != nil {
+		t.Fatalf("NewOrganization: %s", err)
+	}
+	ctx = common.WithTenantID(ctx, organization.ID)
+
+	address := &phonebook.Address{User: &phonebook.User{ID: userID}, Name: "Carol", PhoneNumber: "1"}
+	err = repo.NewAddress(ctx, address)
+	if err != nil {
+		t.Fatalf("NewAddress: %s", err)
+	}
+
+	backend.write = func() {
+		err := repo.UpdateAddress(ctx, address.ID, &phonebook.Address{Name: "Dave", PhoneNumber: "2"})
+		if err != nil {
+			t.Errorf("UpdateAddress: %s", err)
+		}
+	}
+
+	got, err := repo.GetAddressByID(ctx, address.ID)
+	if err != nil || got.Name != "Carol" {
+		t.Fatalf("racing GetAddressByID = %+v, %v, want the row read before the update", got, err)
+	}
+
+	if !backend.primary {
+		t.Fatal("cache filled from a replica")
+	}
+
+	got, err = repo.GetAddressByID(ctx, address.ID)
+	if err != nil || got.Name != "Dave" {
+		t.Fatalf("GetAddressByID after the race = %+v, %v, want Dave", got, err)
+	}
+}
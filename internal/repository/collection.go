@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+)
+
+func (r *store) NewCollection(ctx context.Context, collection *phonebook.Collection) error {
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO collections (user_id, name) VALUES ($1, $2) RETURNING id`,
+		collection.Owner.ID,
+		collection.Name,
+	).Scan(&collection.ID)
+
+	if err != nil {
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (r *store) GetCollectionsByUserID(ctx context.Context, userID int) ([]*phonebook.Collection, error) {
+	res := make([]*phonebook.Collection, 0)
+
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT c.id, c.user_id, c.name FROM collections c
+		WHERE c.user_id = $1 OR EXISTS (
+			SELECT 1 FROM collection_shares s
+			WHERE s.collection_id = c.id AND s.user_id = $1 AND s.accepted_at IS NOT NULL
+		)
+		ORDER BY c.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cur := phonebook.Collection{Owner: &phonebook.User{}}
+		err = rows.Scan(&cur.ID, &cur.Owner.ID, &cur.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &cur)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *store) GetCollectionByID(ctx context.Context, ID int) (*phonebook.Collection, error) {
+	collection := phonebook.Collection{Owner: &phonebook.User{}}
+
+	err := r.conn(ctx).QueryRowContext(ctx, `SELECT id, user_id, name FROM collections WHERE id = $1`, ID).
+		Scan(&collection.ID, &collection.Owner.ID, &collection.Name)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "collection not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
+
+func (r *store) DeleteCollection(ctx context.Context, ID int) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM collections WHERE id = $1`, ID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "collection not found")
+}
+
+func (r *store) GetCollectionPermission(ctx context.Context, collectionID int, userID int) (phonebook.Permission, error) {
+	var permission sql.NullString
+
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT CASE WHEN c.user_id = $2 THEN 'owner' ELSE s.permission END
+		FROM collections c
+		LEFT JOIN collection_shares s
+			ON s.collection_id = c.id AND s.user_id = $2 AND s.accepted_at IS NOT NULL
+		WHERE c.id = $1`,
+		collectionID,
+		userID,
+	).Scan(&permission)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return phonebook.PermissionNone, nil
+	}
+
+	if err != nil {
+		return phonebook.PermissionNone, err
+	}
+
+	return phonebook.Permission(permission.String), nil
+}
+
+func (r *store) NewShare(ctx context.Context, share *phonebook.Share) error {
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO collection_shares (collection_id, user_id, permission) VALUES ($1, $2, $3)
+		ON CONFLICT (collection_id, user_id) DO UPDATE SET permission = EXCLUDED.permission
+		RETURNING id, created_at, accepted_at`,
+		share.Collection.ID,
+		share.User.ID,
+		share.Permission,
+	).Scan(&share.ID, &share.CreatedAt, &share.AcceptedAt)
+
+	if err != nil {
+		return dbError(err)
+	}
+
+	return nil
+}
+
+const shareColumns = `s.id, s.permission, s.created_at, s.accepted_at, s.user_id, u.email, c.id, c.user_id, c.name`
+
+func scanShare(row interface{ Scan(...any) error }) (*phonebook.Share, error) {
+	share := phonebook.Share{
+		User:       &phonebook.User{},
+		Collection: &phonebook.Collection{Owner: &phonebook.User{}},
+	}
+
+	err := row.Scan(
+		&share.ID,
+		&share.Permission,
+		&share.CreatedAt,
+		&share.AcceptedAt,
+		&share.User.ID,
+		&share.User.Email,
+		&share.Collection.ID,
+		&share.Collection.Owner.ID,
+		&share.Collection.Name,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &share, nil
+}
+
+func (r *store) GetShareByID(ctx context.Context, ID int) (*phonebook.Share, error) {
+	share, err := scanShare(r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+shareColumns+` FROM collection_shares s
+		JOIN users u ON u.id = s.user_id
+		JOIN collections c ON c.id = s.collection_id
+		WHERE s.id = $1`,
+		ID,
+	))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "share not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+func (r *store) GetSharesByCollectionID(ctx context.Context, collectionID int) ([]*phonebook.Share, error) {
+	return r.queryShares(
+		ctx,
+		`SELECT `+shareColumns+` FROM collection_shares s
+		JOIN users u ON u.id = s.user_id
+		JOIN collections c ON c.id = s.collection_id
+		WHERE s.collection_id = $1
+		ORDER BY s.id`,
+		collectionID,
+	)
+}
+
+func (r *store) GetPendingSharesByUserID(ctx context.Context, userID int) ([]*phonebook.Share, error) {
+	return r.queryShares(
+		ctx,
+		`SELECT `+shareColumns+` FROM collection_shares s
+		JOIN users u ON u.id = s.user_id
+		JOIN collections c ON c.id = s.collection_id
+		WHERE s.user_id = $1 AND s.accepted_at IS NULL
+		ORDER BY s.id`,
+		userID,
+	)
+}
+
+func (r *store) queryShares(ctx context.Context, query string, args ...any) ([]*phonebook.Share, error) {
+	res := make([]*phonebook.Share, 0)
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, share)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *store) AcceptShare(ctx context.Context, ID int) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `UPDATE collection_shares SET accepted_at = CURRENT_TIMESTAMP WHERE id = $1`, ID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "share not found")
+}
+
+func (r *store) DeleteShare(ctx context.Context, ID int) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM collection_shares WHERE id = $1`, ID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "share not found")
+}
+
+// queryCollectionAddresses returns the tenant's addresses in the collection.
+// A collection is the user's across organizations, so it is filtered by the
+// tenant like any other address query.
+func queryCollectionAddresses(ctx context.Context, q querier, tenantID int, collectionID int) ([]*phonebook.Address, error) {
+	res := make([]*phonebook.Address, 0)
+
+	rows, err := q.QueryContext(
+		ctx,
+		`SELECT id, user_id, name, phone_number FROM addresses WHERE organization_id = $1 AND collection_id = $2 AND deleted_at IS NULL`,
+		tenantID,
+		collectionID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cur := phonebook.Address{
+			User:       &phonebook.User{},
+			Collection: &phonebook.Collection{ID: collectionID},
+		}
+		err = rows.Scan(
+			&cur.ID,
+			&cur.User.ID,
+			&cur.Name,
+			&cur.PhoneNumber,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &cur)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func setAddressCollection(ctx context.Context, tx *sql.Tx, tenantID int, addressID int, collectionID *int) error {
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE addresses SET collection_id = $1 WHERE organization_id = $2 AND id = $3`,
+		collectionID,
+		tenantID,
+		addressID,
+	)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return checkAffected(res, "address not found")
+}
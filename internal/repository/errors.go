@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"template/internal/common"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// conflictMessages describes a unique violation by the table it hit.
+var conflictMessages = map[string]string{
+	"users":             "email already registered",
+	"tags":              "tag already exists",
+	"memberships":       "user already a member",
+	"collection_shares": "collection already shared with user",
+	"user_identities":   "identity already linked to a user",
+}
+
+var sqliteTable = regexp.MustCompile(`constraint failed: (\w+)\.`)
+
+// dbError translates constraint violations and serialization failures of
+// Postgres and SQLite into client errors, and returns other errors as is.
+// A not-null violation means the server failed to fill in a column, not that
+// the request was invalid, so it is not translated.
+func dbError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			return conflictError(pgErr.TableName)
+		case "23503":
+			return common.UnprocessableEntityError{Message: "referenced record does not exist"}
+		case "23514":
+			return common.InvariantError{Message: "invalid value"}
+		case "40001", "40P01":
+			return common.ConflictError{Message: "concurrent update, retry the request"}
+		}
+
+		return err
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			var table string
+			if m := sqliteTable.FindStringSubmatch(sqliteErr.Error()); m != nil {
+				table = m[1]
+			}
+			return conflictError(table)
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return common.UnprocessableEntityError{Message: "referenced record does not exist"}
+		case sqlite3.SQLITE_CONSTRAINT_CHECK:
+			return common.InvariantError{Message: "invalid value"}
+		}
+
+		if sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY {
+			return common.ConflictError{Message: "concurrent update, retry the request"}
+		}
+	}
+
+	return err
+}
+
+func conflictError(table string) error {
+	message, ok := conflictMessages[table]
+	if !ok {
+		message = "already exists"
+	}
+
+	return common.ConflictError{Message: message}
+}
+
+func addressNotFound(deleted bool) error {
+	if deleted {
+		return common.NotFoundError{Message: "address not found in trash"}
+	}
+
+	return common.NotFoundError{Message: "address not found"}
+}
+
+// checkAffected reports a write that changed no rows as a miss.
+func checkAffected(res sql.Result, message string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return common.NotFoundError{Message: message}
+	}
+
+	return nil
+}
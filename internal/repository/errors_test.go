@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"errors"
+	"template/internal/common"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestDBError(t *testing.T) {
+	var ie common.InvariantError
+	if err := dbError(&pgconn.PgError{Code: "23514"}); !errors.As(err, &ie) {
+		t.Fatalf("check violation = %v, want an invariant error", err)
+	}
+
+	notNull := &pgconn.PgError{Code: "23502"}
+	if err := dbError(notNull); err != notNull {
+		t.Fatalf("not-null violation = %v, want it returned as is", err)
+	}
+}
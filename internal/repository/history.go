@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+)
+
+func checkVersion(current *phonebook.Address, expected int) error {
+	if expected != 0 && current.Version != expected {
+		return common.PreconditionFailedError{Message: "address was modified concurrently"}
+	}
+
+	return nil
+}
+
+func recordVersion(ctx context.Context, tx *sql.Tx, action phonebook.Action, before *phonebook.Address, after *phonebook.Address) error {
+	changes, err := json.Marshal(phonebook.DiffAddresses(before, after))
+	if err != nil {
+		return err
+	}
+
+	var actorID *int
+	if id, ok := common.ActorID(ctx); ok {
+		actorID = &id
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO address_versions (address_id, version, actor_id, action, changes, name, phone_number)
+		SELECT $1, coalesce(max(version), 0) + 1, $2, $3, $4, $5, $6
+		FROM address_versions WHERE address_id = $1`,
+		after.ID,
+		actorID,
+		action,
+		changes,
+		after.Name,
+		after.PhoneNumber,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const versionColumns = `address_id, version, actor_id, action, changes, name, phone_number, created_at`
+
+func scanVersion(row interface{ Scan(...any) error }) (*phonebook.AddressVersion, error) {
+	var version phonebook.AddressVersion
+	var actorID sql.NullInt64
+	var changes []byte
+
+	err := row.Scan(
+		&version.AddressID,
+		&version.Version,
+		&actorID,
+		&version.Action,
+		&changes,
+		&version.Name,
+		&version.PhoneNumber,
+		&version.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if actorID.Valid {
+		version.Actor = &phonebook.User{ID: int(actorID.Int64)}
+	}
+
+	err = json.Unmarshal(changes, &version.Changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+func (r *store) GetAddressVersions(ctx context.Context, addressID int) ([]*phonebook.AddressVersion, error) {
+	res := make([]*phonebook.AddressVersion, 0)
+
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT `+versionColumns+` FROM address_versions WHERE address_id = $1 ORDER BY version`,
+		addressID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		version, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, version)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *store) GetAddressVersion(ctx context.Context, addressID int, version int) (*phonebook.AddressVersion, error) {
+	res, err := scanVersion(r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+versionColumns+` FROM address_versions WHERE address_id = $1 AND version = $2`,
+		addressID,
+		version,
+	))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "version not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
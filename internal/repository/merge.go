@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+)
+
+type addressSnapshot struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"user_id"`
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+func newAddressSnapshot(address *phonebook.Address) addressSnapshot {
+	return addressSnapshot{
+		ID:          address.ID,
+		UserID:      address.User.ID,
+		Name:        address.Name,
+		PhoneNumber: address.PhoneNumber,
+	}
+}
+
+func (s addressSnapshot) address() *phonebook.Address {
+	return &phonebook.Address{
+		ID:          s.ID,
+		User:        &phonebook.User{ID: s.UserID},
+		Name:        s.Name,
+		PhoneNumber: s.PhoneNumber,
+	}
+}
+
+func queryMerge(ctx context.Context, q querier, tenantID int, ID int) (*phonebook.Merge, error) {
+	merge := phonebook.Merge{User: &phonebook.User{}}
+	var survivorID, survivorVersion int
+	var previous, merged []byte
+
+	err := q.QueryRowContext(
+		ctx,
+		`SELECT id, user_id, survivor_id, survivor_version, previous, merged, created_at, undone_at FROM address_merges WHERE organization_id = $1 AND id = $2`,
+		tenantID,
+		ID,
+	).Scan(&merge.ID, &merge.User.ID, &survivorID, &survivorVersion, &previous, &merged, &merge.CreatedAt, &merge.UndoneAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "merge not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var previousSnapshot addressSnapshot
+	err = json.Unmarshal(previous, &previousSnapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	var mergedSnapshots []addressSnapshot
+	err = json.Unmarshal(merged, &mergedSnapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	merge.Previous = previousSnapshot.address()
+	merge.Survivor = &phonebook.Address{ID: survivorID, User: merge.User, Version: survivorVersion}
+	for _, snapshot := range mergedSnapshots {
+		merge.Merged = append(merge.Merged, snapshot.address())
+	}
+
+	return &merge, nil
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+)
+
+func (r *store) NewOrganization(ctx context.Context, organization *phonebook.Organization, admin *phonebook.User) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(
+			ctx,
+			`INSERT INTO organizations (name) VALUES ($1) RETURNING id, created_at`,
+			organization.Name,
+		).Scan(&organization.ID, &organization.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO memberships (organization_id, user_id, role) VALUES ($1, $2, $3)`,
+			organization.ID,
+			admin.ID,
+			phonebook.RoleAdmin,
+		)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (r *store) GetOrganizationByID(ctx context.Context, ID int) (*phonebook.Organization, error) {
+	var organization phonebook.Organization
+
+	err := r.conn(ctx).QueryRowContext(ctx, `SELECT id, name, created_at FROM organizations WHERE id = $1`, ID).
+		Scan(&organization.ID, &organization.Name, &organization.CreatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "organization not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &organization, nil
+}
+
+const membershipColumns = `o.id, o.name, o.created_at, u.id, u.email, m.role, m.created_at`
+
+func scanMembership(row interface{ Scan(...any) error }) (*phonebook.Membership, error) {
+	membership := phonebook.Membership{
+		Organization: &phonebook.Organization{},
+		User:         &phonebook.User{},
+	}
+
+	err := row.Scan(
+		&membership.Organization.ID,
+		&membership.Organization.Name,
+		&membership.Organization.CreatedAt,
+		&membership.User.ID,
+		&membership.User.Email,
+		&membership.Role,
+		&membership.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+func (r *store) GetMembership(ctx context.Context, organizationID int, userID int) (*phonebook.Membership, error) {
+	membership, err := scanMembership(r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+membershipColumns+` FROM memberships m
+		JOIN organizations o ON o.id = m.organization_id
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1 AND m.user_id = $2`,
+		organizationID,
+		userID,
+	))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "member not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+func (r *store) GetMembershipsByUserID(ctx context.Context, userID int) ([]*phonebook.Membership, error) {
+	return r.queryMemberships(
+		ctx,
+		`SELECT `+membershipColumns+` FROM memberships m
+		JOIN organizations o ON o.id = m.organization_id
+		JOIN users u ON u.id = m.user_id
+		WHERE m.user_id = $1
+		ORDER BY m.created_at, o.id`,
+		userID,
+	)
+}
+
+func (r *store) GetMembershipsByOrganizationID(ctx context.Context, organizationID int) ([]*phonebook.Membership, error) {
+	return r.queryMemberships(
+		ctx,
+		`SELECT `+membershipColumns+` FROM memberships m
+		JOIN organizations o ON o.id = m.organization_id
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY m.created_at, u.id`,
+		organizationID,
+	)
+}
+
+func (r *store) queryMemberships(ctx context.Context, query string, args ...any) ([]*phonebook.Membership, error) {
+	res := make([]*phonebook.Membership, 0)
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		membership, err := scanMembership(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, membership)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *store) NewMembership(ctx context.Context, membership *phonebook.Membership) error {
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO memberships (organization_id, user_id, role) VALUES ($1, $2, $3) RETURNING created_at`,
+		membership.Organization.ID,
+		membership.User.ID,
+		membership.Role,
+	).Scan(&membership.CreatedAt)
+
+	if err != nil {
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (r *store) DeleteMembership(ctx context.Context, organizationID int, userID int) error {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`DELETE FROM memberships WHERE organization_id = $1 AND user_id = $2`,
+		organizationID,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "member not found")
+}
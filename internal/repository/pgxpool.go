@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// PgxPoolRepository runs on a pgxpool.Pool. The hottest reads use the pool
+// natively, where pgx caches prepared statements per connection; everything
+// else goes through the embedded PostgreSQLRepository on the same pool.
+type PgxPoolRepository struct {
+	*PostgreSQLRepository
+	pool *pgxpool.Pool
+}
+
+func NewPgxPoolRepository(pool *pgxpool.Pool) *PgxPoolRepository {
+	return &PgxPoolRepository{NewPostgreSQLRepository(stdlib.OpenDBFromPool(pool)), pool}
+}
+
+// native returns the tenant when a read can bypass database/sql. Inside a
+// unit of work, or with row-level security, reads must use the transaction;
+// with replicas they are routed by PostgreSQLRepository.
+func (r *PgxPoolRepository) native(ctx context.Context) (int, bool) {
+	if r.rls || r.replicas != nil {
+		return 0, false
+	}
+
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return 0, false
+	}
+
+	return common.TenantID(ctx)
+}
+
+func (r *PgxPoolRepository) Addresses(ctx context.Context) ([]*phonebook.Address, error) {
+	tenantID, ok := r.native(ctx)
+	if !ok {
+		return r.PostgreSQLRepository.Addresses(ctx)
+	}
+
+	rows, err := r.pool.Query(ctx, tenantAddressesQuery, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectAddresses(rows)
+}
+
+func (r *PgxPoolRepository) GetAddressesByUserID(ctx context.Context, userID int) ([]*phonebook.Address, error) {
+	tenantID, ok := r.native(ctx)
+	if !ok {
+		return r.PostgreSQLRepository.GetAddressesByUserID(ctx, userID)
+	}
+
+	rows, err := r.pool.Query(
+		ctx,
+		userAddressesQuery,
+		tenantID,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectAddresses(rows)
+}
+
+func (r *PgxPoolRepository) GetAddressByID(ctx context.Context, ID int) (*phonebook.Address, error) {
+	tenantID, ok := r.native(ctx)
+	if !ok {
+		return r.PostgreSQLRepository.GetAddressByID(ctx, ID)
+	}
+
+	address := phonebook.Address{User: &phonebook.User{}}
+	var collectionID *int
+
+	err := r.pool.QueryRow(
+		ctx,
+		addressByIDQuery,
+		tenantID,
+		ID,
+	).Scan(&address.ID, &address.User.ID, &collectionID, &address.Name, &address.PhoneNumber, &address.Version)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "address not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if collectionID != nil {
+		address.Collection = &phonebook.Collection{ID: *collectionID}
+	}
+
+	return &address, nil
+}
+
+func collectAddresses(rows pgx.Rows) ([]*phonebook.Address, error) {
+	defer rows.Close()
+
+	res := make([]*phonebook.Address, 0)
+	for rows.Next() {
+		cur := phonebook.Address{User: &phonebook.User{}}
+		err := rows.Scan(
+			&cur.ID,
+			&cur.User.ID,
+			&cur.Name,
+			&cur.PhoneNumber,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &cur)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
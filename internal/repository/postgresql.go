@@ -4,71 +4,92 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strconv"
+	"template/internal/common"
+	"template/internal/config"
 	"template/internal/phonebook"
 )
 
+var errMissingTenant = errors.New("tenant missing from context")
+
+// Address reads shared between backends. The pgxpool fast paths run the same
+// text as PostgreSQLRepository so the two cannot drift apart.
+const (
+	tenantAddressesQuery = `SELECT id, user_id, name, phone_number FROM addresses WHERE organization_id = $1 AND deleted_at IS NULL`
+	userAddressesQuery   = `SELECT id, user_id, name, phone_number FROM addresses WHERE organization_id = $1 AND user_id = $2 AND deleted_at IS NULL`
+	addressByIDQuery     = `SELECT id, user_id, collection_id, name, phone_number, version FROM addresses WHERE organization_id = $1 AND id = $2 AND deleted_at IS NULL`
+
+	// visibleAddressesQuery mirrors phonebook.AddressPermission: a user sees
+	// their own addresses and those in collections they own or have accepted
+	// a share of.
+	visibleAddressesQuery = `SELECT id, user_id, name, phone_number FROM addresses WHERE organization_id = $1 AND deleted_at IS NULL AND (
+		user_id = $2
+		OR collection_id IN (SELECT id FROM collections WHERE user_id = $2)
+		OR collection_id IN (SELECT collection_id FROM collection_shares WHERE user_id = $2 AND accepted_at IS NOT NULL)
+	)`
+)
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type PostgreSQLRepository struct {
-	db *sql.DB
+	store
+	rls      bool
+	replicas *Replicas
 }
 
 func NewPostgreSQLRepository(db *sql.DB) *PostgreSQLRepository {
-	return &PostgreSQLRepository{db}
+	return &PostgreSQLRepository{store: store{db}, rls: config.DATABASE_RLS == "true"}
 }
 
-func (r *PostgreSQLRepository) NewUser(ctx context.Context, user *phonebook.User) (int, error) {
-	var id int
-
-	err := r.db.QueryRowContext(
-		ctx,
-		`INSERT INTO users (email, password) VALUES ($1, $2) RETURNING ID`,
-		user.Email,
-		user.Password,
-	).Scan(&id)
+// scoped runs fn for the tenant carried by ctx. Queries in fn must filter by
+// the tenant themselves; with row-level security enabled they additionally
+// run in a transaction that sets app.tenant_id for the policies in rls.sql.
+func (r *PostgreSQLRepository) scoped(ctx context.Context, fn func(q querier, tenantID int) error) error {
+	if !r.rls {
+		tenantID, ok := common.TenantID(ctx)
+		if !ok {
+			return errMissingTenant
+		}
 
-	if err != nil {
-		return -1, err
+		return fn(r.conn(ctx), tenantID)
 	}
 
-	return id, nil
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		return fn(tx, tenantID)
+	})
 }
 
-func (r *PostgreSQLRepository) GetUserByEmail(ctx context.Context, email string) (*phonebook.User, error) {
-	var user phonebook.User
-
-	err := r.db.QueryRowContext(ctx, `SELECT id, email, password FROM users WHERE email = $1`, email).
-		Scan(&user.ID, &user.Email, &user.Password)
-
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, nil
+func (r *PostgreSQLRepository) scopedTx(ctx context.Context, fn func(tx *sql.Tx, tenantID int) error) error {
+	tenantID, ok := common.TenantID(ctx)
+	if !ok {
+		return errMissingTenant
 	}
 
-	if err != nil {
-		return nil, err
-	}
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if r.rls {
+			err := setTenant(ctx, tx, tenantID)
+			if err != nil {
+				return err
+			}
+		}
 
-	return &user, nil
+		return fn(tx, tenantID)
+	})
 }
 
-func (r *PostgreSQLRepository) NewAddress(ctx context.Context, address *phonebook.Address) error {
-	_, err := r.db.ExecContext(
-		ctx,
-		`INSERT INTO addresses (user_id, name, phone_number) VALUES ($1, $2, $3)`,
-		address.User.ID,
-		address.Name,
-		address.PhoneNumber,
-	)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+func setTenant(ctx context.Context, tx *sql.Tx, tenantID int) error {
+	_, err := tx.ExecContext(ctx, `SELECT set_config('app.tenant_id', $1, true)`, strconv.Itoa(tenantID))
+	return err
 }
 
-func (r *PostgreSQLRepository) Addresses(ctx context.Context) ([]*phonebook.Address, error) {
+func queryAddresses(ctx context.Context, q querier, query string, args ...any) ([]*phonebook.Address, error) {
 	res := make([]*phonebook.Address, 0)
 
-	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, name, phone_number FROM addresses`)
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -97,31 +118,109 @@ func (r *PostgreSQLRepository) Addresses(ctx context.Context) ([]*phonebook.Addr
 	return res, nil
 }
 
-func (r *PostgreSQLRepository) GetAddressesByUserID(ctx context.Context, userID int) ([]*phonebook.Address, error) {
-	res := make([]*phonebook.Address, 0)
+func (r *PostgreSQLRepository) NewAddress(ctx context.Context, address *phonebook.Address) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		return insertAddress(ctx, tx, tenantID, address)
+	})
+}
+
+func insertAddress(ctx context.Context, tx *sql.Tx, tenantID int, address *phonebook.Address) error {
+	var collectionID *int
+	if address.Collection != nil {
+		collectionID = &address.Collection.ID
+	}
+
+	err := tx.QueryRowContext(
+		ctx,
+		`INSERT INTO addresses (organization_id, user_id, collection_id, name, phone_number) VALUES ($1, $2, $3, $4, $5) RETURNING id, version`,
+		tenantID,
+		address.User.ID,
+		collectionID,
+		address.Name,
+		address.PhoneNumber,
+	).Scan(&address.ID, &address.Version)
 
-	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, name, phone_number FROM addresses WHERE user_id = $1`, userID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		cur := phonebook.Address{User: &phonebook.User{}}
-		err = rows.Scan(
-			&cur.ID,
-			&cur.User.ID,
-			&cur.Name,
-			&cur.PhoneNumber,
-		)
+	return recordVersion(ctx, tx, phonebook.ActionCreate, nil, address)
+}
+
+func (r *PostgreSQLRepository) NewAddresses(ctx context.Context, addresses []*phonebook.Address) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		stmt, err := tx.PrepareContext(ctx, `INSERT INTO addresses (organization_id, user_id, name, phone_number) VALUES ($1, $2, $3, $4) RETURNING id`)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		defer stmt.Close()
+
+		for _, address := range addresses {
+			err = stmt.QueryRowContext(ctx, tenantID, address.User.ID, address.Name, address.PhoneNumber).Scan(&address.ID)
+			if err != nil {
+				return err
+			}
+
+			err = recordVersion(ctx, tx, phonebook.ActionCreate, nil, address)
+			if err != nil {
+				return err
+			}
 		}
 
-		res = append(res, &cur)
+		return nil
+	})
+}
+
+func (r *PostgreSQLRepository) Addresses(ctx context.Context) ([]*phonebook.Address, error) {
+	var res []*phonebook.Address
+
+	err := r.read(ctx, func(q querier, tenantID int) error {
+		var err error
+		res, err = queryAddresses(ctx, q, tenantAddressesQuery, tenantID)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	err = rows.Err()
+	return res, nil
+}
+
+func (r *PostgreSQLRepository) GetAddressesByUserID(ctx context.Context, userID int) ([]*phonebook.Address, error) {
+	var res []*phonebook.Address
+
+	err := r.read(ctx, func(q querier, tenantID int) error {
+		var err error
+		res, err = queryAddresses(
+			ctx,
+			q,
+			userAddressesQuery,
+			tenantID,
+			userID,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *PostgreSQLRepository) GetVisibleAddresses(ctx context.Context, userID int) ([]*phonebook.Address, error) {
+	var res []*phonebook.Address
+
+	err := r.read(ctx, func(q querier, tenantID int) error {
+		var err error
+		res, err = queryAddresses(
+			ctx,
+			q,
+			visibleAddressesQuery,
+			tenantID,
+			userID,
+		)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -131,41 +230,132 @@ func (r *PostgreSQLRepository) GetAddressesByUserID(ctx context.Context, userID
 
 func (r *PostgreSQLRepository) GetAddressByID(ctx context.Context, ID int) (*phonebook.Address, error) {
 	address := phonebook.Address{User: &phonebook.User{}}
+	var collectionID sql.NullInt64
 
-	err := r.db.QueryRowContext(ctx, `SELECT id, user_id, name, phone_number FROM addresses WHERE id = $1`, ID).
-		Scan(&address.ID, &address.User.ID, &address.Name, &address.PhoneNumber)
+	err := r.read(ctx, func(q querier, tenantID int) error {
+		return q.QueryRowContext(
+			ctx,
+			addressByIDQuery,
+			tenantID,
+			ID,
+		).Scan(&address.ID, &address.User.ID, &collectionID, &address.Name, &address.PhoneNumber, &address.Version)
+	})
 
 	if errors.Is(err, sql.ErrNoRows) {
-		return nil, nil
+		return nil, common.NotFoundError{Message: "address not found"}
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	if collectionID.Valid {
+		address.Collection = &phonebook.Collection{ID: int(collectionID.Int64)}
+	}
+
 	return &address, nil
 }
 
+func (r *PostgreSQLRepository) SearchAddresses(ctx context.Context, userID int, query string, limit int) ([]*phonebook.Address, error) {
+	var res []*phonebook.Address
+
+	err := r.read(ctx, func(q querier, tenantID int) error {
+		var err error
+		res, err = queryAddresses(
+			ctx,
+			q,
+			`SELECT id, user_id, name, phone_number
+			FROM (
+				SELECT id, user_id, name, phone_number,
+					ts_rank(to_tsvector('simple', name), plainto_tsquery('simple', $3)) AS text_rank,
+					similarity(name, $3) AS name_rank,
+					CASE WHEN $4 <> '' AND regexp_replace(phone_number, '\D', '', 'g') LIKE '%' || $4 || '%'
+						THEN 1 ELSE 0 END AS phone_rank
+				FROM addresses
+				WHERE organization_id = $1 AND user_id = $2 AND deleted_at IS NULL AND (
+					to_tsvector('simple', name) @@ plainto_tsquery('simple', $3)
+					OR name % $3
+					OR ($4 <> '' AND regexp_replace(phone_number, '\D', '', 'g') LIKE '%' || $4 || '%')
+				)
+			) AS matches
+			ORDER BY text_rank + name_rank + phone_rank DESC, id
+			LIMIT $5`,
+			tenantID,
+			userID,
+			query,
+			phonebook.Digits(query),
+			limit,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (r *PostgreSQLRepository) UpdateAddress(ctx context.Context, ID int, address *phonebook.Address) error {
-	_, err := r.db.ExecContext(
-		ctx,
-		`UPDATE addresses SET name = $1, phone_number = $2 WHERE id = $3`,
-		address.Name,
-		address.PhoneNumber,
-		ID,
-	)
+	return r.updateAddress(ctx, ID, address, phonebook.ActionUpdate)
+}
+
+func (r *PostgreSQLRepository) DeleteAddress(ctx context.Context, ID int, version int) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		return deleteAddress(ctx, tx, tenantID, ID, version)
+	})
+}
+
+func deleteAddress(ctx context.Context, tx *sql.Tx, tenantID int, ID int, version int) error {
+	address, err := lockAddress(ctx, tx, tenantID, ID, false)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	err = checkVersion(address, version)
+	if err != nil {
+		return err
+	}
 
-func (r *PostgreSQLRepository) DeleteAddress(ctx context.Context, ID int) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM addresses WHERE id = $1`, ID)
+	res, err := tx.ExecContext(ctx, `UPDATE addresses SET deleted_at = now(), version = version + 1 WHERE id = $1`, ID)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	err = checkAffected(res, "address not found")
+	if err != nil {
+		return err
+	}
+
+	return recordVersion(ctx, tx, phonebook.ActionDelete, address, address)
+}
+
+// DeleteOrganization runs under the organization's tenant, so row-level
+// security lets it remove the organization's addresses.
+func (r *PostgreSQLRepository) DeleteOrganization(ctx context.Context, ID int) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if r.rls {
+			err := setTenant(ctx, tx, ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return deleteOrganization(ctx, tx, ID)
+	})
+}
+
+// DeleteUser removes the user's addresses through delete_user_addresses
+// from rls.sql when row-level security is on, as they span organizations
+// and the policy hides addresses from a transaction without a tenant.
+func (r *PostgreSQLRepository) DeleteUser(ctx context.Context, ID int) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if r.rls {
+			_, err := tx.ExecContext(ctx, `SELECT delete_user_addresses($1)`, ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return deleteUser(ctx, tx, ID)
+	})
 }
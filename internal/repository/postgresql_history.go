@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"template/internal/phonebook"
+)
+
+// lockAddress reads an address of the tenant and locks it until tx ends, so
+// the version recorded for a change is computed against what it replaced.
+func lockAddress(ctx context.Context, tx *sql.Tx, tenantID int, ID int, deleted bool) (*phonebook.Address, error) {
+	address := phonebook.Address{User: &phonebook.User{}}
+	var collectionID sql.NullInt64
+
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT id, user_id, collection_id, name, phone_number, version FROM addresses
+		WHERE organization_id = $1 AND id = $2 AND (deleted_at IS NOT NULL) = $3
+		FOR UPDATE`,
+		tenantID,
+		ID,
+		deleted,
+	).Scan(&address.ID, &address.User.ID, &collectionID, &address.Name, &address.PhoneNumber, &address.Version)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, addressNotFound(deleted)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if collectionID.Valid {
+		address.Collection = &phonebook.Collection{ID: int(collectionID.Int64)}
+	}
+
+	return &address, nil
+}
+
+func (r *PostgreSQLRepository) LockAddress(ctx context.Context, ID int, deleted bool) (*phonebook.Address, error) {
+	var address *phonebook.Address
+
+	err := r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		var err error
+		address, err = lockAddress(ctx, tx, tenantID, ID, deleted)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+func (r *PostgreSQLRepository) RevertAddress(ctx context.Context, ID int, to *phonebook.AddressVersion) error {
+	return r.updateAddress(ctx, ID, &phonebook.Address{Name: to.Name, PhoneNumber: to.PhoneNumber}, phonebook.ActionRevert)
+}
+
+func (r *PostgreSQLRepository) updateAddress(ctx context.Context, ID int, address *phonebook.Address, action phonebook.Action) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		return updateAddress(ctx, tx, tenantID, ID, address, action)
+	})
+}
+
+// updateAddress writes only the columns that differ from the stored address
+// and records the change as action.
+func updateAddress(ctx context.Context, tx *sql.Tx, tenantID int, ID int, address *phonebook.Address, action phonebook.Action) error {
+	before, err := lockAddress(ctx, tx, tenantID, ID, false)
+	if err != nil {
+		return err
+	}
+
+	err = checkVersion(before, address.Version)
+	if err != nil {
+		return err
+	}
+
+	after := &phonebook.Address{ID: ID, User: before.User, Name: address.Name, PhoneNumber: address.PhoneNumber}
+
+	changes := phonebook.DiffAddresses(before, after)
+	if len(changes) == 0 {
+		address.Version = before.Version
+		return nil
+	}
+
+	sets := make([]string, 0, len(changes))
+	args := make([]any, 0, len(changes)+1)
+	for _, column := range []string{phonebook.ColumnName, phonebook.ColumnPhoneNumber} {
+		if change, ok := changes[column]; ok {
+			args = append(args, change.To)
+			sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+		}
+	}
+	args = append(args, ID)
+
+	err = tx.QueryRowContext(
+		ctx,
+		fmt.Sprintf(`UPDATE addresses SET %s, version = version + 1 WHERE id = $%d RETURNING version`, strings.Join(sets, ", "), len(args)),
+		args...,
+	).Scan(&address.Version)
+	if err != nil {
+		return err
+	}
+
+	return recordVersion(ctx, tx, action, before, after)
+}
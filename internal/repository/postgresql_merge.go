@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+)
+
+func (r *PostgreSQLRepository) GetMergeByID(ctx context.Context, ID int) (*phonebook.Merge, error) {
+	var merge *phonebook.Merge
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		var err error
+		merge, err = queryMerge(ctx, q, tenantID, ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+func (r *PostgreSQLRepository) MergeAddresses(ctx context.Context, merge *phonebook.Merge) error {
+	previous, err := json.Marshal(newAddressSnapshot(merge.Previous))
+	if err != nil {
+		return err
+	}
+
+	snapshots := make([]addressSnapshot, 0, len(merge.Merged))
+	ids := make([]int, 0, len(merge.Merged))
+	for _, address := range merge.Merged {
+		snapshots = append(snapshots, newAddressSnapshot(address))
+		ids = append(ids, address.ID)
+	}
+
+	merged, err := json.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		current, err := lockAddress(ctx, tx, tenantID, merge.Survivor.ID, false)
+		if err != nil {
+			return err
+		}
+
+		err = checkVersion(current, merge.Previous.Version)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(
+			ctx,
+			`UPDATE addresses SET name = $1, phone_number = $2, version = version + 1
+			WHERE organization_id = $3 AND id = $4 AND deleted_at IS NULL
+			RETURNING version`,
+			merge.Survivor.Name,
+			merge.Survivor.PhoneNumber,
+			tenantID,
+			merge.Survivor.ID,
+		).Scan(&merge.Survivor.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: "address not found"}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = recordVersion(ctx, tx, phonebook.ActionMerge, merge.Previous, merge.Survivor)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO address_tags (address_id, tag_id)
+			SELECT $1, tag_id FROM address_tags WHERE address_id = ANY($2)
+			ON CONFLICT DO NOTHING`,
+			merge.Survivor.ID,
+			toInt64s(ids),
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, address := range merge.Merged {
+			current, err := lockAddress(ctx, tx, tenantID, address.ID, false)
+			if err != nil {
+				return err
+			}
+
+			err = checkVersion(current, address.Version)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE addresses SET deleted_at = now(), version = version + 1 WHERE id = $1`, address.ID)
+			if err != nil {
+				return err
+			}
+
+			err = recordVersion(ctx, tx, phonebook.ActionDelete, current, current)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.QueryRowContext(
+			ctx,
+			`INSERT INTO address_merges (organization_id, user_id, survivor_id, survivor_version, previous, merged) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+			tenantID,
+			merge.User.ID,
+			merge.Survivor.ID,
+			merge.Survivor.Version,
+			previous,
+			merged,
+		).Scan(&merge.ID, &merge.CreatedAt)
+	})
+}
+
+func (r *PostgreSQLRepository) UndoMerge(ctx context.Context, merge *phonebook.Merge) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		current, err := lockAddress(ctx, tx, tenantID, merge.Survivor.ID, false)
+		if err != nil {
+			return err
+		}
+
+		if current.Version != merge.Survivor.Version {
+			return common.ConflictError{Message: "address was changed after the merge, undo is no longer possible"}
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE addresses SET name = $1, phone_number = $2, version = version + 1 WHERE id = $3`,
+			merge.Previous.Name,
+			merge.Previous.PhoneNumber,
+			merge.Survivor.ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = recordVersion(ctx, tx, phonebook.ActionRevert, current, merge.Previous)
+		if err != nil {
+			return err
+		}
+
+		for _, address := range merge.Merged {
+			merged, err := lockAddress(ctx, tx, tenantID, address.ID, true)
+			if errors.As(err, &common.NotFoundError{}) {
+				return common.ConflictError{Message: "a merged address is no longer in the trash, undo is no longer possible"}
+			}
+
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE addresses SET deleted_at = NULL, version = version + 1 WHERE id = $1`, address.ID)
+			if err != nil {
+				return err
+			}
+
+			err = recordVersion(ctx, tx, phonebook.ActionRestore, merged, merged)
+			if err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, `UPDATE address_merges SET undone_at = now() WHERE organization_id = $1 AND id = $2 AND undone_at IS NULL`, tenantID, merge.ID)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return common.InvariantError{Message: "merge already undone"}
+		}
+
+		return nil
+	})
+}
+
+// GetDuplicatePairs finds the pairs with pg_trgm, whose % operator can use
+// the trigram index on names. Phone numbers are normalized the way
+// phonebook.NormalizePhoneNumber does it.
+func (r *PostgreSQLRepository) GetDuplicatePairs(ctx context.Context, userID int, limit int) ([]phonebook.DuplicatePair, error) {
+	res := make([]phonebook.DuplicatePair, 0)
+
+	err := r.read(ctx, func(q querier, tenantID int) error {
+		rows, err := q.QueryContext(
+			ctx,
+			`WITH candidates AS (
+				SELECT id, name, right(ltrim(regexp_replace(phone_number, '\D', '', 'g'), '0'), 9) AS phone
+				FROM addresses
+				WHERE organization_id = $1 AND user_id = $2 AND deleted_at IS NULL
+			)
+			SELECT a.id, b.id, a.phone <> '' AND a.phone = b.phone, similarity(a.name, b.name) >= $3
+			FROM candidates a
+			JOIN candidates b ON a.id < b.id AND ((a.phone <> '' AND a.phone = b.phone) OR a.name % b.name)
+			WHERE (a.phone <> '' AND a.phone = b.phone) OR similarity(a.name, b.name) >= $3
+			ORDER BY a.id, b.id
+			LIMIT $4`,
+			tenantID,
+			userID,
+			phonebook.NameSimilarityThreshold,
+			limit,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var pair phonebook.DuplicatePair
+			var phone, name bool
+			err = rows.Scan(&pair.First, &pair.Second, &phone, &name)
+			if err != nil {
+				return err
+			}
+
+			if phone {
+				pair.Reasons = append(pair.Reasons, phonebook.ReasonPhoneNumber)
+			}
+			if name {
+				pair.Reasons = append(pair.Reasons, phonebook.ReasonName)
+			}
+
+			res = append(res, pair)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
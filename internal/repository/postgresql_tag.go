@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"template/internal/phonebook"
+)
+
+func (r *PostgreSQLRepository) TagAddresses(ctx context.Context, tagIDs []int, addressIDs []int) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO address_tags (address_id, tag_id)
+		SELECT address_id, tag_id FROM unnest($1::BIGINT[]) AS address_id, unnest($2::BIGINT[]) AS tag_id
+		ON CONFLICT DO NOTHING`,
+		toInt64s(addressIDs),
+		toInt64s(tagIDs),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (r *PostgreSQLRepository) UntagAddresses(ctx context.Context, tagIDs []int, addressIDs []int) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`DELETE FROM address_tags WHERE address_id = ANY($1) AND tag_id = ANY($2)`,
+		toInt64s(addressIDs),
+		toInt64s(tagIDs),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *PostgreSQLRepository) GetAddressesByTag(ctx context.Context, userID int, tag string) ([]*phonebook.Address, error) {
+	var res []*phonebook.Address
+
+	err := r.read(ctx, func(q querier, tenantID int) error {
+		var err error
+		res, err = queryAddresses(
+			ctx,
+			q,
+			`SELECT a.id, a.user_id, a.name, a.phone_number
+			FROM addresses a
+			JOIN address_tags at ON at.address_id = a.id
+			JOIN tags t ON t.id = at.tag_id
+			WHERE a.organization_id = $1 AND a.deleted_at IS NULL AND t.user_id = $2 AND t.name = $3`,
+			tenantID,
+			userID,
+			tag,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func toInt64s(ids []int) []int64 {
+	res := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, int64(id))
+	}
+
+	return res
+}
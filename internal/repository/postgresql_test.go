@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"template/internal/repository/repotest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// testDatabaseURL names a Postgres database, as a postgres:// URL, that the
+// Postgres tests may create schemas in. They are skipped without it.
+const testDatabaseURL = "TEST_DATABASE_URL"
+
+var schemaSeq atomic.Int64
+
+func requirePostgreSQL(t testing.TB) string {
+	t.Helper()
+
+	dsn := os.Getenv(testDatabaseURL)
+	if dsn == "" {
+		t.Skipf("%s not set", testDatabaseURL)
+	}
+
+	return dsn
+}
+
+// newPostgreSQLSchema creates a schema with init.sql and then scripts
+// applied, dropped when the test ends, and returns a DSN whose connections
+// use it.
+func newPostgreSQLSchema(t testing.TB, scripts ...string) string {
+	t.Helper()
+
+	dsn := requirePostgreSQL(t)
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("%s: %s", testDatabaseURL, err)
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	defer admin.Close()
+
+	ctx := context.Background()
+	schema := fmt.Sprintf("repotest_%d_%d", time.Now().UnixNano(), schemaSeq.Add(1))
+
+	_, err = admin.ExecContext(ctx, `CREATE SCHEMA `+schema)
+	if err != nil {
+		t.Fatalf("create schema: %s", err)
+	}
+
+	t.Cleanup(func() {
+		admin, err := sql.Open("pgx", dsn)
+		if err != nil {
+			t.Errorf("open: %s", err)
+			return
+		}
+		defer admin.Close()
+
+		_, err = admin.ExecContext(context.Background(), `DROP SCHEMA `+schema+` CASCADE`)
+		if err != nil {
+			t.Errorf("drop schema: %s", err)
+		}
+	})
+
+	conn, err := admin.Conn(ctx)
+	if err != nil {
+		t.Fatalf("conn: %s", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `SET search_path TO `+schema+`, public`)
+	if err != nil {
+		t.Fatalf("set search_path: %s", err)
+	}
+
+	for _, name := range append([]string{"init.sql"}, scripts...) {
+		script, err := os.ReadFile("../../" + name)
+		if err != nil {
+			t.Fatalf("read %s: %s", name, err)
+		}
+
+		_, err = conn.ExecContext(ctx, string(script))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+	}
+
+	query := u.Query()
+	query.Set("search_path", schema+",public")
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+func newPostgreSQLRepository(t testing.TB, scripts ...string) *PostgreSQLRepository {
+	t.Helper()
+
+	conn, err := sql.Open("pgx", newPostgreSQLSchema(t, scripts...))
+	if err != nil {
+		t.Fatalf("open: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return NewPostgreSQLRepository(conn)
+}
+
+func newPgxPoolRepository(t testing.TB) *PgxPoolRepository {
+	t.Helper()
+
+	pool, err := pgxpool.New(context.Background(), newPostgreSQLSchema(t))
+	if err != nil {
+		t.Fatalf("pgxpool: %s", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return NewPgxPoolRepository(pool)
+}
+
+func TestPostgreSQLConformance(t *testing.T) {
+	requirePostgreSQL(t)
+
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return newPostgreSQLRepository(t)
+	})
+}
+
+// TestPostgreSQLRLSConformance runs with rls.sql applied, which needs a
+// superuser. Superusers bypass the policies, so against one it only checks
+// that the repository and the maintenance functions work together.
+func TestPostgreSQLRLSConformance(t *testing.T) {
+	requirePostgreSQL(t)
+
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		repo := newPostgreSQLRepository(t, "rls.sql")
+		repo.rls = true
+		return repo
+	})
+}
+
+func TestPgxPoolConformance(t *testing.T) {
+	requirePostgreSQL(t)
+
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return newPgxPoolRepository(t)
+	})
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"time"
+)
+
+func (r *PostgreSQLRepository) GetDeletedAddressesByUserID(ctx context.Context, userID int) ([]*phonebook.Address, error) {
+	res := make([]*phonebook.Address, 0)
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		rows, err := q.QueryContext(
+			ctx,
+			`SELECT id, user_id, name, phone_number, deleted_at FROM addresses
+			WHERE organization_id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+			ORDER BY deleted_at DESC`,
+			tenantID,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			cur := phonebook.Address{User: &phonebook.User{}}
+			err = rows.Scan(
+				&cur.ID,
+				&cur.User.ID,
+				&cur.Name,
+				&cur.PhoneNumber,
+				&cur.DeletedAt,
+			)
+			if err != nil {
+				return err
+			}
+
+			res = append(res, &cur)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *PostgreSQLRepository) GetDeletedAddressByID(ctx context.Context, ID int) (*phonebook.Address, error) {
+	address := phonebook.Address{User: &phonebook.User{}}
+	var collectionID sql.NullInt64
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		return q.QueryRowContext(
+			ctx,
+			`SELECT id, user_id, collection_id, name, phone_number, deleted_at FROM addresses
+			WHERE organization_id = $1 AND id = $2 AND deleted_at IS NOT NULL`,
+			tenantID,
+			ID,
+		).Scan(&address.ID, &address.User.ID, &collectionID, &address.Name, &address.PhoneNumber, &address.DeletedAt)
+	})
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "address not found in trash"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if collectionID.Valid {
+		address.Collection = &phonebook.Collection{ID: int(collectionID.Int64)}
+	}
+
+	return &address, nil
+}
+
+func (r *PostgreSQLRepository) RestoreAddress(ctx context.Context, ID int) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		address, err := lockAddress(ctx, tx, tenantID, ID, true)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `UPDATE addresses SET deleted_at = NULL, version = version + 1 WHERE id = $1`, ID)
+		if err != nil {
+			return err
+		}
+
+		err = checkAffected(res, "address not found in trash")
+		if err != nil {
+			return err
+		}
+
+		return recordVersion(ctx, tx, phonebook.ActionRestore, address, address)
+	})
+}
+
+// PurgeDeletedAddresses goes through purge_deleted_addresses from rls.sql
+// when row-level security is on, as the trash spans organizations.
+func (r *PostgreSQLRepository) PurgeDeletedAddresses(ctx context.Context, before time.Time) (int, error) {
+	if r.rls {
+		var n int
+		err := r.conn(ctx).QueryRowContext(ctx, `SELECT purge_deleted_addresses($1)`, before).Scan(&n)
+		if err != nil {
+			return 0, err
+		}
+
+		return n, nil
+	}
+
+	res, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM addresses WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"template/internal/common"
+	"time"
+)
+
+const replicaCheckTimeout = 2 * time.Second
+
+// Replicas spreads reads across read replicas of the primary. A replica
+// only takes reads after passing a health check, and leaves the rotation
+// when a check or a read on it fails.
+type Replicas struct {
+	dbs     []*sql.DB
+	healthy []atomic.Bool
+	next    atomic.Uint64
+}
+
+func NewReplicas(dbs ...*sql.DB) *Replicas {
+	return &Replicas{dbs: dbs, healthy: make([]atomic.Bool, len(dbs))}
+}
+
+// Check pings every replica and updates its health.
+func (r *Replicas) Check(ctx context.Context) {
+	for i, db := range r.dbs {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+		err := db.PingContext(pingCtx)
+		cancel()
+
+		if err != nil {
+			r.fail(i, err)
+			continue
+		}
+
+		if !r.healthy[i].Swap(true) {
+			common.Log.Infof("replica %d is healthy", i)
+		}
+	}
+}
+
+// Watch checks the replicas every interval until ctx is done.
+func (r *Replicas) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Replicas) Close() error {
+	var res error
+	for _, db := range r.dbs {
+		err := db.Close()
+		if err != nil && res == nil {
+			res = err
+		}
+	}
+
+	return res
+}
+
+// pick returns the next healthy replica, round robin.
+func (r *Replicas) pick() (int, *sql.DB, bool) {
+	n := uint64(len(r.dbs))
+	start := r.next.Add(1)
+
+	for i := uint64(0); i < n; i++ {
+		idx := int((start + i) % n)
+		if r.healthy[idx].Load() {
+			return idx, r.dbs[idx], true
+		}
+	}
+
+	return 0, nil, false
+}
+
+func (r *Replicas) fail(i int, err error) {
+	if r.healthy[i].Swap(false) {
+		common.Log.Warnf("replica %d is unhealthy: %s", i, err)
+	}
+}
+
+// UseReplicas routes the reads that tolerate replication lag to replicas.
+func (r *PostgreSQLRepository) UseReplicas(replicas *Replicas) {
+	r.replicas = replicas
+}
+
+// read runs fn like scoped, on a replica when one is healthy. Reads inside a
+// unit of work or pinned to the primary with common.WithPrimary stay on the
+// primary, and a read that fails on a replica is retried there.
+func (r *PostgreSQLRepository) read(ctx context.Context, fn func(q querier, tenantID int) error) error {
+	if r.replicas == nil || common.Primary(ctx) {
+		return r.scoped(ctx, fn)
+	}
+
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return r.scoped(ctx, fn)
+	}
+
+	i, replica, ok := r.replicas.pick()
+	if !ok {
+		return r.scoped(ctx, fn)
+	}
+
+	err := r.scopedOn(ctx, replica, fn)
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, errMissingTenant) || ctx.Err() != nil {
+		return err
+	}
+
+	r.replicas.fail(i, err)
+
+	return r.scoped(ctx, fn)
+}
+
+func (r *PostgreSQLRepository) scopedOn(ctx context.Context, db *sql.DB, fn func(q querier, tenantID int) error) error {
+	tenantID, ok := common.TenantID(ctx)
+	if !ok {
+		return errMissingTenant
+	}
+
+	if !r.rls {
+		return fn(db, tenantID)
+	}
+
+	return inTx(ctx, db, func(tx *sql.Tx) error {
+		err := setTenant(ctx, tx, tenantID)
+		if err != nil {
+			return err
+		}
+
+		return fn(tx, tenantID)
+	})
+}
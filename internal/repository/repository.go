@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"time"
+)
+
+// Repository is everything the server needs from a storage backend. Reads
+// and writes of a record that does not exist report a common.NotFoundError.
+type Repository interface {
+	phonebook.UserRepository
+	phonebook.AddressRepository
+	phonebook.DuplicateRepository
+	phonebook.TagRepository
+	phonebook.CollectionRepository
+	phonebook.OrganizationRepository
+	phonebook.AuditRepository
+	phonebook.SessionRepository
+	phonebook.AccountRepository
+	ReserveIdempotencyKey(context.Context, *common.IdempotencyRecord) (*common.IdempotencyRecord, error)
+	CompleteIdempotencyKey(context.Context, *common.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error)
+}
+
+var (
+	_ Repository = (*PostgreSQLRepository)(nil)
+	_ Repository = (*PgxPoolRepository)(nil)
+	_ Repository = (*SQLiteRepository)(nil)
+)
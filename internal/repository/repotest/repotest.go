@@ -0,0 +1,1100 @@
+// Package repotest is a conformance suite for phonebook repositories. A
+// backend runs it from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repotest.Repository {
+//			return newEmptyRepository(t)
+//		})
+//	}
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"testing"
+	"time"
+)
+
+type Repository interface {
+	phonebook.UserRepository
+	phonebook.AddressRepository
+	phonebook.DuplicateRepository
+	phonebook.CollectionRepository
+	phonebook.OrganizationRepository
+	phonebook.SessionRepository
+	phonebook.TagRepository
+	DeleteUser(ctx context.Context, ID int) error
+}
+
+// Run runs every conformance test. newRepo must return an empty repository
+// that no other test shares.
+func Run(t *testing.T, newRepo func(t *testing.T) Repository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo Repository)
+	}{
+		{"Users", testUsers},
+		{"UserNotFound", testUserNotFound},
+		{"DuplicateEmail", testDuplicateEmail},
+		{"MissingReference", testMissingReference},
+		{"Addresses", testAddresses},
+		{"AddressNotFound", testAddressNotFound},
+		{"TrashedNotFound", testTrashedNotFound},
+		{"ServiceNotFound", testServiceNotFound},
+		{"Search", testSearch},
+		{"Tags", testTags},
+		{"TenantIsolation", testTenantIsolation},
+		{"CollectionTenantIsolation", testCollectionTenantIsolation},
+		{"MergeTenantIsolation", testMergeTenantIsolation},
+		{"MissingTenant", testMissingTenant},
+		{"RemovedMember", testRemovedMember},
+		{"AddressVisibility", testAddressVisibility},
+		{"DeleteUser", testDeleteUser},
+		{"Trash", testTrash},
+		{"Purge", testPurge},
+		{"History", testHistory},
+		{"DuplicatePairs", testDuplicatePairs},
+		{"UndoMerge", testUndoMerge},
+		{"UndoMergeAfterEdit", testUndoMergeAfterEdit},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"Transaction", testTransaction},
+		{"Cancellation", testCancellation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+// tenant creates a user with an organization of their own and returns the
+// context their requests run with.
+func tenant(t *testing.T, repo Repository, email string) (context.Context, int) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	userID, err := repo.NewUser(ctx, &phonebook.User{Email: email, Password: "password"})
+	if err != nil {
+		t.Fatalf("NewUser: %s", err)
+	}
+
+	organization := &phonebook.Organization{Name: email}
+	err = repo.NewOrganization(ctx, organization, &phonebook.User{ID: userID})
+	if err != nil {
+		t.Fatalf("NewOrganization: %s", err)
+	}
+
+	return common.WithActorID(common.WithTenantID(ctx, organization.ID), userID), userID
+}
+
+func newAddress(t *testing.T, repo Repository, ctx context.Context, userID int, name string) *phonebook.Address {
+	t.Helper()
+
+	address := &phonebook.Address{User: &phonebook.User{ID: userID}, Name: name, PhoneNumber: "+1 555 0100"}
+
+	err := repo.NewAddress(ctx, address)
+	if err != nil {
+		t.Fatalf("NewAddress: %s", err)
+	}
+
+	return address
+}
+
+func testUsers(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	id, err := repo.NewUser(ctx, &phonebook.User{Email: "alice@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatalf("NewUser: %s", err)
+	}
+
+	user, err := repo.GetUserByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %s", err)
+	}
+
+	if user == nil || user.ID != id || user.Email != "alice@example.com" || user.Password != "hash" {
+		t.Fatalf("GetUserByEmail = %+v, want user %d", user, id)
+	}
+}
+
+func testUserNotFound(t *testing.T, repo Repository) {
+	_, err := repo.GetUserByEmail(context.Background(), "nobody@example.com")
+	wantNotFound(t, "GetUserByEmail", err)
+}
+
+func testDuplicateEmail(t *testing.T, repo Repository) {
+	ctx := context.Background()
+
+	_, err := repo.NewUser(ctx, &phonebook.User{Email: "alice@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatalf("NewUser: %s", err)
+	}
+
+	_, err = repo.NewUser(ctx, &phonebook.User{Email: "alice@example.com", Password: "hash"})
+	var ce common.ConflictError
+	if !errors.As(err, &ce) {
+		t.Fatalf("NewUser with a registered email = %v, want a ConflictError", err)
+	}
+}
+
+func testMissingReference(t *testing.T, repo Repository) {
+	ctx, _ := tenant(t, repo, "alice@example.com")
+
+	err := repo.NewAddress(ctx, &phonebook.Address{User: &phonebook.User{ID: 404}, Name: "Bob", PhoneNumber: "1"})
+	var ue common.UnprocessableEntityError
+	if !errors.As(err, &ue) {
+		t.Fatalf("NewAddress for a missing user = %v, want an UnprocessableEntityError", err)
+	}
+}
+
+func testAddresses(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+
+	address := newAddress(t, repo, ctx, userID, "Bob")
+	if address.ID == 0 || address.Version != 1 {
+		t.Fatalf("NewAddress left ID %d, version %d", address.ID, address.Version)
+	}
+
+	got, err := repo.GetAddressByID(ctx, address.ID)
+	if err != nil {
+		t.Fatalf("GetAddressByID: %s", err)
+	}
+
+	if got == nil || got.Name != "Bob" || got.User.ID != userID || got.Version != 1 {
+		t.Fatalf("GetAddressByID = %+v, want Bob owned by %d", got, userID)
+	}
+
+	update := &phonebook.Address{Name: "Robert", PhoneNumber: address.PhoneNumber}
+	err = repo.UpdateAddress(ctx, address.ID, update)
+	if err != nil {
+		t.Fatalf("UpdateAddress: %s", err)
+	}
+
+	if update.Version != 2 {
+		t.Fatalf("UpdateAddress left version %d, want 2", update.Version)
+	}
+
+	addresses, err := repo.GetAddressesByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetAddressesByUserID: %s", err)
+	}
+
+	if len(addresses) != 1 || addresses[0].Name != "Robert" {
+		t.Fatalf("GetAddressesByUserID = %v, want Robert", addresses)
+	}
+
+	err = repo.DeleteAddress(ctx, address.ID, 0)
+	if err != nil {
+		t.Fatalf("DeleteAddress: %s", err)
+	}
+
+	_, err = repo.GetAddressByID(ctx, address.ID)
+	wantNotFound(t, "GetAddressByID after delete", err)
+}
+
+func testAddressNotFound(t *testing.T, repo Repository) {
+	ctx, _ := tenant(t, repo, "alice@example.com")
+
+	_, err := repo.GetAddressByID(ctx, 404)
+	wantNotFound(t, "GetAddressByID", err)
+
+	_, err = repo.GetDeletedAddressByID(ctx, 404)
+	wantNotFound(t, "GetDeletedAddressByID", err)
+
+	_, err = repo.GetAddressVersion(ctx, 404, 1)
+	wantNotFound(t, "GetAddressVersion", err)
+
+	err = repo.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := repo.LockAddress(ctx, 404, false)
+		return err
+	})
+	wantNotFound(t, "LockAddress", err)
+
+	err = repo.UpdateAddress(ctx, 404, &phonebook.Address{Name: "Bob", PhoneNumber: "1"})
+	wantNotFound(t, "UpdateAddress", err)
+
+	err = repo.DeleteAddress(ctx, 404, 0)
+	wantNotFound(t, "DeleteAddress", err)
+
+	err = repo.RestoreAddress(ctx, 404)
+	wantNotFound(t, "RestoreAddress", err)
+
+	err = repo.RevertAddress(ctx, 404, &phonebook.AddressVersion{Name: "Bob", PhoneNumber: "1"})
+	wantNotFound(t, "RevertAddress", err)
+}
+
+// testTrashedNotFound checks that trashed and live addresses miss for the
+// operations that expect the other kind.
+func testTrashedNotFound(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+
+	live := newAddress(t, repo, ctx, userID, "Bob")
+	trashed := newAddress(t, repo, ctx, userID, "Carol")
+
+	err := repo.DeleteAddress(ctx, trashed.ID, 0)
+	if err != nil {
+		t.Fatalf("DeleteAddress: %s", err)
+	}
+
+	_, err = repo.GetDeletedAddressByID(ctx, live.ID)
+	wantNotFound(t, "GetDeletedAddressByID of a live address", err)
+
+	err = repo.RestoreAddress(ctx, live.ID)
+	wantNotFound(t, "RestoreAddress of a live address", err)
+
+	err = repo.DeleteAddress(ctx, trashed.ID, 0)
+	wantNotFound(t, "DeleteAddress of a trashed address", err)
+
+	err = repo.UpdateAddress(ctx, trashed.ID, &phonebook.Address{Name: "Dave", PhoneNumber: "1"})
+	wantNotFound(t, "UpdateAddress of a trashed address", err)
+}
+
+// testSearch checks what every backend's search guarantees, however it
+// ranks: exact names come first, phone numbers match by their digits, and
+// only the user's live addresses are found.
+func testSearch(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+	bobCtx, bobID := tenant(t, repo, "bob@example.com")
+
+	add := func(ctx context.Context, userID int, name string, phoneNumber string) *phonebook.Address {
+		t.Helper()
+
+		address := &phonebook.Address{User: &phonebook.User{ID: userID}, Name: name, PhoneNumber: phoneNumber}
+		err := repo.NewAddress(ctx, address)
+		if err != nil {
+			t.Fatalf("NewAddress: %s", err)
+		}
+
+		return address
+	}
+
+	add(ctx, userID, "Ann Marie Lee", "+1 555 0100")
+	ann := add(ctx, userID, "Ann Lee", "+1 555 0101")
+	frank := add(ctx, userID, "Frank", "(030) 123-4567")
+	trashed := add(ctx, userID, "Ann Lee", "+1 555 0102")
+	add(bobCtx, bobID, "Ann Lee", "030 1234567")
+
+	err := repo.DeleteAddress(ctx, trashed.ID, 0)
+	if err != nil {
+		t.Fatalf("DeleteAddress: %s", err)
+	}
+
+	got, err := repo.SearchAddresses(ctx, userID, "Ann Lee", 10)
+	if err != nil {
+		t.Fatalf("SearchAddresses: %s", err)
+	}
+
+	if len(got) == 0 || got[0].ID != ann.ID {
+		t.Fatalf("SearchAddresses(Ann Lee) = %v, want Ann Lee first", got)
+	}
+
+	for _, address := range got {
+		if address.ID == trashed.ID || address.User.ID != userID {
+			t.Fatalf("SearchAddresses found address %d of user %d, want only alice's live addresses", address.ID, address.User.ID)
+		}
+	}
+
+	got, err = repo.SearchAddresses(ctx, userID, "030-1234", 10)
+	if err != nil {
+		t.Fatalf("SearchAddresses: %s", err)
+	}
+
+	if len(got) != 1 || got[0].ID != frank.ID {
+		t.Fatalf("SearchAddresses(030-1234) = %v, want Frank", got)
+	}
+
+	got, err = repo.SearchAddresses(ctx, userID, "Ann", 1)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("SearchAddresses with a limit of 1 = %v, %v, want one address", got, err)
+	}
+}
+
+// testTags checks tagging and untagging in bulk, that tag names are unique
+// per user, and that listing by tag sees only the user's tag and live
+// addresses.
+func testTags(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+	bobCtx, bobID := tenant(t, repo, "bob@example.com")
+
+	newTag := func(ctx context.Context, userID int, name string) *phonebook.Tag {
+		t.Helper()
+
+		tag := &phonebook.Tag{User: &phonebook.User{ID: userID}, Name: name}
+		err := repo.NewTag(ctx, tag)
+		if err != nil {
+			t.Fatalf("NewTag: %s", err)
+		}
+
+		return tag
+	}
+
+	tagged := func(tag string, want ...int) {
+		t.Helper()
+
+		addresses, err := repo.GetAddressesByTag(ctx, userID, tag)
+		if err != nil {
+			t.Fatalf("GetAddressesByTag: %s", err)
+		}
+
+		got := make([]int, 0, len(addresses))
+		for _, address := range addresses {
+			got = append(got, address.ID)
+		}
+		sort.Ints(got)
+
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("GetAddressesByTag(%s) = %v, want %v", tag, got, want)
+		}
+	}
+
+	friends := newTag(ctx, userID, "friends")
+	work := newTag(ctx, userID, "work")
+	newTag(bobCtx, bobID, "friends")
+
+	err := repo.NewTag(ctx, &phonebook.Tag{User: &phonebook.User{ID: userID}, Name: "friends"})
+	if !errors.As(err, &common.ConflictError{}) {
+		t.Fatalf("NewTag with a taken name = %v, want a ConflictError", err)
+	}
+
+	ann := newAddress(t, repo, ctx, userID, "Ann")
+	bob := newAddress(t, repo, ctx, userID, "Bob")
+	carol := newAddress(t, repo, ctx, userID, "Carol")
+
+	for i := 0; i < 2; i++ {
+		err = repo.TagAddresses(ctx, []int{friends.ID, work.ID}, []int{ann.ID, bob.ID, carol.ID})
+		if err != nil {
+			t.Fatalf("TagAddresses, time %d: %s", i+1, err)
+		}
+	}
+
+	tagged("friends", ann.ID, bob.ID, carol.ID)
+
+	err = repo.UntagAddresses(ctx, []int{friends.ID}, []int{bob.ID})
+	if err != nil {
+		t.Fatalf("UntagAddresses: %s", err)
+	}
+
+	err = repo.DeleteAddress(ctx, carol.ID, 0)
+	if err != nil {
+		t.Fatalf("DeleteAddress: %s", err)
+	}
+
+	tagged("friends", ann.ID)
+	tagged("work", ann.ID, bob.ID)
+	tagged("family")
+
+	err = repo.DeleteTag(ctx, work.ID)
+	if err != nil {
+		t.Fatalf("DeleteTag: %s", err)
+	}
+
+	tagged("work")
+}
+
+// testServiceNotFound checks that the address service passes misses on to
+// its callers rather than failing on them.
+func testServiceNotFound(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+	svc := phonebook.NewAddressService(repo, phonebook.DefaultMaxBatchSize)
+
+	_, err := svc.GetAddressByID(ctx, userID, 404)
+	wantNotFound(t, "AddressService.GetAddressByID", err)
+
+	err = svc.UpdateAddress(ctx, userID, 404, &phonebook.Address{Name: "Bob", PhoneNumber: "1"})
+	wantNotFound(t, "AddressService.UpdateAddress", err)
+
+	err = svc.DeleteAddress(ctx, userID, 404, 0)
+	wantNotFound(t, "AddressService.DeleteAddress", err)
+
+	err = svc.RestoreAddress(ctx, userID, 404)
+	wantNotFound(t, "AddressService.RestoreAddress", err)
+
+	err = svc.RevertAddress(ctx, userID, 404, 1)
+	wantNotFound(t, "AddressService.RevertAddress", err)
+
+	_, err = svc.History(ctx, userID, 404)
+	wantNotFound(t, "AddressService.History", err)
+
+	address := newAddress(t, repo, ctx, userID, "Bob")
+
+	err = svc.RevertAddress(ctx, userID, address.ID, 404)
+	wantNotFound(t, "AddressService.RevertAddress to a missing version", err)
+}
+
+func wantNotFound(t *testing.T, op string, err error) {
+	t.Helper()
+
+	var nf common.NotFoundError
+	if !errors.As(err, &nf) {
+		t.Fatalf("%s on a miss = %v, want a NotFoundError", op, err)
+	}
+}
+
+func testTenantIsolation(t *testing.T, repo Repository) {
+	aliceCtx, aliceID := tenant(t, repo, "alice@example.com")
+	bobCtx, _ := tenant(t, repo, "bob@example.com")
+
+	address := newAddress(t, repo, aliceCtx, aliceID, "Carol")
+
+	_, err := repo.GetAddressByID(bobCtx, address.ID)
+	wantNotFound(t, "GetAddressByID from another tenant", err)
+
+	err = repo.UpdateAddress(bobCtx, address.ID, &phonebook.Address{Name: "Mallory", PhoneNumber: "1"})
+	wantNotFound(t, "UpdateAddress from another tenant", err)
+
+	err = repo.DeleteAddress(bobCtx, address.ID, 0)
+	wantNotFound(t, "DeleteAddress from another tenant", err)
+
+	addresses, err := repo.Addresses(bobCtx)
+	if err != nil {
+		t.Fatalf("Addresses: %s", err)
+	}
+
+	if len(addresses) != 0 {
+		t.Fatalf("Addresses from another tenant = %v, want none", addresses)
+	}
+}
+
+// testCollectionTenantIsolation checks that a collection, which belongs to
+// the user rather than an organization, only holds the tenant's addresses.
+func testCollectionTenantIsolation(t *testing.T, repo Repository) {
+	aliceCtx, aliceID := tenant(t, repo, "alice@example.com")
+	bobCtx, _ := tenant(t, repo, "bob@example.com")
+
+	address := newAddress(t, repo, aliceCtx, aliceID, "Carol")
+
+	collection := &phonebook.Collection{Owner: &phonebook.User{ID: aliceID}, Name: "Friends"}
+	err := repo.NewCollection(aliceCtx, collection)
+	if err != nil {
+		t.Fatalf("NewCollection: %s", err)
+	}
+
+	err = repo.SetAddressCollection(aliceCtx, address.ID, &collection.ID)
+	if err != nil {
+		t.Fatalf("SetAddressCollection: %s", err)
+	}
+
+	err = repo.SetAddressCollection(bobCtx, address.ID, nil)
+	wantNotFound(t, "SetAddressCollection from another tenant", err)
+
+	addresses, err := repo.GetAddressesByCollectionID(bobCtx, collection.ID)
+	if err != nil {
+		t.Fatalf("GetAddressesByCollectionID: %s", err)
+	}
+
+	if len(addresses) != 0 {
+		t.Fatalf("GetAddressesByCollectionID from another tenant = %v, want none", addresses)
+	}
+
+	addresses, err = repo.GetAddressesByCollectionID(aliceCtx, collection.ID)
+	if err != nil || len(addresses) != 1 {
+		t.Fatalf("GetAddressesByCollectionID = %v, %v, want Carol", addresses, err)
+	}
+}
+
+func testMergeTenantIsolation(t *testing.T, repo Repository) {
+	aliceCtx, aliceID := tenant(t, repo, "alice@example.com")
+	bobCtx, _ := tenant(t, repo, "bob@example.com")
+
+	survivor := newAddress(t, repo, aliceCtx, aliceID, "Frank")
+	duplicate := newAddress(t, repo, aliceCtx, aliceID, "Frank Miller")
+
+	merge, err := phonebook.NewDuplicateService(repo).Merge(aliceCtx, aliceID, survivor.ID, []int{duplicate.ID}, &phonebook.Address{Name: "Frank M"})
+	if err != nil {
+		t.Fatalf("Merge: %s", err)
+	}
+
+	_, err = repo.GetMergeByID(bobCtx, merge.ID)
+	wantNotFound(t, "GetMergeByID from another tenant", err)
+
+	_, err = repo.GetMergeByID(aliceCtx, merge.ID)
+	if err != nil {
+		t.Fatalf("GetMergeByID: %s", err)
+	}
+}
+
+func testMissingTenant(t *testing.T, repo Repository) {
+	_, err := repo.Addresses(context.Background())
+	if err == nil {
+		t.Fatal("Addresses without a tenant succeeded")
+	}
+}
+
+// testRemovedMember checks that a token stops working for an organization
+// once its user is removed from it.
+func testRemovedMember(t *testing.T, repo Repository) {
+	aliceCtx, _ := tenant(t, repo, "alice@example.com")
+	organizationID, _ := common.TenantID(aliceCtx)
+
+	ctx := context.Background()
+
+	bobID, err := repo.NewUser(ctx, &phonebook.User{Email: "bob@example.com", Password: "password"})
+	if err != nil {
+		t.Fatalf("NewUser: %s", err)
+	}
+
+	err = repo.NewMembership(ctx, &phonebook.Membership{
+		Organization: &phonebook.Organization{ID: organizationID},
+		User:         &phonebook.User{ID: bobID},
+		Role:         phonebook.RoleMember,
+	})
+	if err != nil {
+		t.Fatalf("NewMembership: %s", err)
+	}
+
+	session := &phonebook.Session{ID: "bob", UserID: bobID, DeviceName: "test", ExpiresAt: time.Now().Add(time.Hour)}
+	err = repo.NewSession(ctx, session)
+	if err != nil {
+		t.Fatalf("NewSession: %s", err)
+	}
+
+	svc := phonebook.NewSessionService(repo)
+
+	err = svc.Authenticate(ctx, bobID, organizationID, session.ID)
+	if err != nil {
+		t.Fatalf("Authenticate as a member: %s", err)
+	}
+
+	err = repo.DeleteMembership(ctx, organizationID, bobID)
+	if err != nil {
+		t.Fatalf("DeleteMembership: %s", err)
+	}
+
+	err = svc.Authenticate(ctx, bobID, organizationID, session.ID)
+	if !errors.As(err, &common.AuthenticationError{}) {
+		t.Fatalf("Authenticate after removal = %v, want an authentication error", err)
+	}
+}
+
+// testAddressVisibility checks that bob, a member of alice's organization,
+// reads only his own addresses and those of a collection alice shared with
+// him once he accepted the share.
+func testAddressVisibility(t *testing.T, repo Repository) {
+	aliceCtx, aliceID := tenant(t, repo, "alice@example.com")
+	organizationID, _ := common.TenantID(aliceCtx)
+
+	ctx := context.Background()
+
+	bobID, err := repo.NewUser(ctx, &phonebook.User{Email: "bob@example.com", Password: "password"})
+	if err != nil {
+		t.Fatalf("NewUser: %s", err)
+	}
+
+	err = repo.NewMembership(ctx, &phonebook.Membership{
+		Organization: &phonebook.Organization{ID: organizationID},
+		User:         &phonebook.User{ID: bobID},
+		Role:         phonebook.RoleMember,
+	})
+	if err != nil {
+		t.Fatalf("NewMembership: %s", err)
+	}
+
+	bobCtx := common.WithActorID(aliceCtx, bobID)
+
+	private := newAddress(t, repo, aliceCtx, aliceID, "Carol")
+	shared := newAddress(t, repo, aliceCtx, aliceID, "Dave")
+	own := newAddress(t, repo, bobCtx, bobID, "Erin")
+
+	collection := &phonebook.Collection{Owner: &phonebook.User{ID: aliceID}, Name: "Friends"}
+	err = repo.NewCollection(aliceCtx, collection)
+	if err != nil {
+		t.Fatalf("NewCollection: %s", err)
+	}
+
+	err = repo.SetAddressCollection(aliceCtx, shared.ID, &collection.ID)
+	if err != nil {
+		t.Fatalf("SetAddressCollection: %s", err)
+	}
+
+	share := &phonebook.Share{Collection: collection, User: &phonebook.User{ID: bobID}, Permission: phonebook.PermissionViewer}
+	err = repo.NewShare(aliceCtx, share)
+	if err != nil {
+		t.Fatalf("NewShare: %s", err)
+	}
+
+	visible := func(want ...int) {
+		t.Helper()
+
+		addresses, err := repo.GetVisibleAddresses(bobCtx, bobID)
+		if err != nil {
+			t.Fatalf("GetVisibleAddresses: %s", err)
+		}
+
+		got := make([]int, 0, len(addresses))
+		for _, address := range addresses {
+			got = append(got, address.ID)
+		}
+		sort.Ints(got)
+
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("GetVisibleAddresses = %v, want %v", got, want)
+		}
+	}
+
+	visible(own.ID)
+
+	err = repo.AcceptShare(bobCtx, share.ID)
+	if err != nil {
+		t.Fatalf("AcceptShare: %s", err)
+	}
+
+	visible(shared.ID, own.ID)
+
+	svc := phonebook.NewAddressService(repo, phonebook.DefaultMaxBatchSize)
+
+	_, err = svc.GetAddressByID(bobCtx, bobID, shared.ID)
+	if err != nil {
+		t.Fatalf("AddressService.GetAddressByID of a shared address: %s", err)
+	}
+
+	_, err = svc.GetAddressByID(bobCtx, bobID, private.ID)
+	if !errors.As(err, &common.AuthorizationError{}) {
+		t.Fatalf("AddressService.GetAddressByID of a private address = %v, want an authorization error", err)
+	}
+
+	addresses, err := svc.Addresses(aliceCtx, aliceID)
+	if err != nil || len(addresses) != 2 {
+		t.Fatalf("AddressService.Addresses = %v, %v, want Carol and Dave", addresses, err)
+	}
+}
+
+// testDeleteUser deletes bob, a member of alice's organization who edited
+// one of her addresses and filed it in his collection, and checks that what
+// was his goes with him while her address stays.
+func testDeleteUser(t *testing.T, repo Repository) {
+	aliceCtx, aliceID := tenant(t, repo, "alice@example.com")
+	organizationID, _ := common.TenantID(aliceCtx)
+
+	ctx := context.Background()
+
+	bobID, err := repo.NewUser(ctx, &phonebook.User{Email: "bob@example.com", Password: "password"})
+	if err != nil {
+		t.Fatalf("NewUser: %s", err)
+	}
+
+	err = repo.NewMembership(ctx, &phonebook.Membership{
+		Organization: &phonebook.Organization{ID: organizationID},
+		User:         &phonebook.User{ID: bobID},
+		Role:         phonebook.RoleMember,
+	})
+	if err != nil {
+		t.Fatalf("NewMembership: %s", err)
+	}
+
+	err = repo.NewSession(ctx, &phonebook.Session{ID: "bob", UserID: bobID, DeviceName: "test", ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("NewSession: %s", err)
+	}
+
+	bobCtx := common.WithActorID(aliceCtx, bobID)
+
+	carol := newAddress(t, repo, aliceCtx, aliceID, "Carol")
+	dave := newAddress(t, repo, bobCtx, bobID, "Dave")
+
+	collection := &phonebook.Collection{Owner: &phonebook.User{ID: bobID}, Name: "Bob's"}
+	err = repo.NewCollection(bobCtx, collection)
+	if err != nil {
+		t.Fatalf("NewCollection: %s", err)
+	}
+
+	err = repo.SetAddressCollection(bobCtx, carol.ID, &collection.ID)
+	if err != nil {
+		t.Fatalf("SetAddressCollection: %s", err)
+	}
+
+	err = repo.UpdateAddress(bobCtx, carol.ID, &phonebook.Address{Name: "Carol B", PhoneNumber: carol.PhoneNumber})
+	if err != nil {
+		t.Fatalf("UpdateAddress: %s", err)
+	}
+
+	err = repo.DeleteUser(ctx, bobID)
+	if err != nil {
+		t.Fatalf("DeleteUser: %s", err)
+	}
+
+	_, err = repo.GetUserByEmail(ctx, "bob@example.com")
+	wantNotFound(t, "GetUserByEmail of a deleted user", err)
+
+	_, err = repo.GetSession(ctx, "bob")
+	wantNotFound(t, "GetSession of a deleted user", err)
+
+	_, err = repo.GetAddressByID(aliceCtx, dave.ID)
+	wantNotFound(t, "GetAddressByID of a deleted user's address", err)
+
+	address, err := repo.GetAddressByID(aliceCtx, carol.ID)
+	if err != nil {
+		t.Fatalf("GetAddressByID: %s", err)
+	}
+
+	if address.Collection != nil {
+		t.Fatalf("address is still in deleted collection %d", address.Collection.ID)
+	}
+
+	versions, err := repo.GetAddressVersions(aliceCtx, carol.ID)
+	if err != nil {
+		t.Fatalf("GetAddressVersions: %s", err)
+	}
+
+	for _, version := range versions {
+		if version.Actor != nil && version.Actor.ID == bobID {
+			t.Fatalf("version %d still names the deleted user", version.Version)
+		}
+	}
+
+	err = repo.DeleteUser(ctx, bobID)
+	wantNotFound(t, "DeleteUser twice", err)
+}
+
+func testTrash(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+
+	address := newAddress(t, repo, ctx, userID, "Dave")
+
+	err := repo.DeleteAddress(ctx, address.ID, 0)
+	if err != nil {
+		t.Fatalf("DeleteAddress: %s", err)
+	}
+
+	trashed, err := repo.GetDeletedAddressByID(ctx, address.ID)
+	if err != nil {
+		t.Fatalf("GetDeletedAddressByID: %s", err)
+	}
+
+	if trashed == nil || trashed.DeletedAt == nil {
+		t.Fatalf("GetDeletedAddressByID = %+v, want a deletion time", trashed)
+	}
+
+	err = repo.RestoreAddress(ctx, address.ID)
+	if err != nil {
+		t.Fatalf("RestoreAddress: %s", err)
+	}
+
+	got, err := repo.GetAddressByID(ctx, address.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetAddressByID after restore = %+v, %v", got, err)
+	}
+}
+
+// testPurge checks that purging the trash, which runs without a tenant,
+// reaches every organization and leaves addresses that are not trashed.
+func testPurge(t *testing.T, repo Repository) {
+	aliceCtx, aliceID := tenant(t, repo, "alice@example.com")
+	bobCtx, bobID := tenant(t, repo, "bob@example.com")
+
+	kept := newAddress(t, repo, aliceCtx, aliceID, "Dave")
+	erin := newAddress(t, repo, aliceCtx, aliceID, "Erin")
+	frank := newAddress(t, repo, bobCtx, bobID, "Frank")
+
+	err := repo.DeleteAddress(aliceCtx, erin.ID, 0)
+	if err != nil {
+		t.Fatalf("DeleteAddress: %s", err)
+	}
+
+	err = repo.DeleteAddress(bobCtx, frank.ID, 0)
+	if err != nil {
+		t.Fatalf("DeleteAddress: %s", err)
+	}
+
+	n, err := repo.PurgeDeletedAddresses(context.Background(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeDeletedAddresses: %s", err)
+	}
+
+	if n != 2 {
+		t.Fatalf("PurgeDeletedAddresses = %d, want 2", n)
+	}
+
+	_, err = repo.GetAddressByID(aliceCtx, kept.ID)
+	if err != nil {
+		t.Fatalf("GetAddressByID of an address not in the trash: %s", err)
+	}
+}
+
+func testHistory(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+
+	address := newAddress(t, repo, ctx, userID, "Erin")
+
+	err := repo.UpdateAddress(ctx, address.ID, &phonebook.Address{Name: "Erin B", PhoneNumber: address.PhoneNumber})
+	if err != nil {
+		t.Fatalf("UpdateAddress: %s", err)
+	}
+
+	versions, err := repo.GetAddressVersions(ctx, address.ID)
+	if err != nil {
+		t.Fatalf("GetAddressVersions: %s", err)
+	}
+
+	if len(versions) != 2 || versions[0].Action != phonebook.ActionCreate || versions[1].Action != phonebook.ActionUpdate {
+		t.Fatalf("GetAddressVersions = %v, want create then update", versions)
+	}
+
+	if change := versions[1].Changes[phonebook.ColumnName]; change.From != "Erin" || change.To != "Erin B" {
+		t.Fatalf("update recorded %+v, want Erin -> Erin B", change)
+	}
+
+	if versions[1].Actor == nil || versions[1].Actor.ID != userID {
+		t.Fatalf("update recorded actor %+v, want %d", versions[1].Actor, userID)
+	}
+}
+
+// testDuplicatePairs checks that both backends agree on which addresses
+// look alike: numbers equal once normalized, or names at least
+// NameSimilarityThreshold alike, among the user's live addresses.
+func testDuplicatePairs(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+	bobCtx, bobID := tenant(t, repo, "bob@example.com")
+
+	add := func(ctx context.Context, userID int, name string, phoneNumber string) int {
+		t.Helper()
+
+		address := &phonebook.Address{User: &phonebook.User{ID: userID}, Name: name, PhoneNumber: phoneNumber}
+		err := repo.NewAddress(ctx, address)
+		if err != nil {
+			t.Fatalf("NewAddress: %s", err)
+		}
+
+		return address.ID
+	}
+
+	ann := add(ctx, userID, "Ann Lee", "1")
+	anna := add(ctx, userID, "Anna Lee", "2")
+	leeds := add(ctx, userID, "Anna Leeds", "3")
+	frank := add(ctx, userID, "Frank Miller", "+49 30 1234567")
+	fred := add(ctx, userID, "Fred", "030 123-45-67")
+	add(ctx, userID, "Frank", "4")
+	trashed := add(ctx, userID, "Fred", "5")
+	add(bobCtx, bobID, "Ann Lee", "1")
+
+	err := repo.DeleteAddress(ctx, trashed, 0)
+	if err != nil {
+		t.Fatalf("DeleteAddress: %s", err)
+	}
+
+	pairs, err := repo.GetDuplicatePairs(ctx, userID, phonebook.MaxDuplicatePairs)
+	if err != nil {
+		t.Fatalf("GetDuplicatePairs: %s", err)
+	}
+
+	want := []phonebook.DuplicatePair{
+		{First: ann, Second: anna, Reasons: []string{phonebook.ReasonName}},
+		{First: anna, Second: leeds, Reasons: []string{phonebook.ReasonName}},
+		{First: frank, Second: fred, Reasons: []string{phonebook.ReasonPhoneNumber}},
+	}
+	if fmt.Sprint(pairs) != fmt.Sprint(want) {
+		t.Fatalf("GetDuplicatePairs = %v, want %v", pairs, want)
+	}
+
+	pairs, err = repo.GetDuplicatePairs(ctx, userID, 1)
+	if err != nil || len(pairs) != 1 {
+		t.Fatalf("GetDuplicatePairs with a limit of 1 = %v, %v, want one pair", pairs, err)
+	}
+
+	clusters, err := phonebook.NewDuplicateService(repo).FindDuplicates(ctx, userID)
+	if err != nil {
+		t.Fatalf("FindDuplicates: %s", err)
+	}
+
+	if len(clusters) != 2 || len(clusters[0].Addresses) != 2 || len(clusters[1].Addresses) != 2 {
+		t.Fatalf("FindDuplicates = %d clusters, want Ann Lee with Anna Lee and Frank with Fred", len(clusters))
+	}
+}
+
+func testUndoMerge(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+	svc := phonebook.NewDuplicateService(repo)
+
+	survivor := newAddress(t, repo, ctx, userID, "Frank")
+	duplicate := newAddress(t, repo, ctx, userID, "Frank Miller")
+
+	merge, err := svc.Merge(ctx, userID, survivor.ID, []int{duplicate.ID}, &phonebook.Address{Name: "Frank M"})
+	if err != nil {
+		t.Fatalf("Merge: %s", err)
+	}
+
+	_, err = repo.GetDeletedAddressByID(ctx, duplicate.ID)
+	if err != nil {
+		t.Fatalf("GetDeletedAddressByID of a merged address: %s", err)
+	}
+
+	err = svc.UndoMerge(ctx, userID, merge.ID)
+	if err != nil {
+		t.Fatalf("UndoMerge: %s", err)
+	}
+
+	got, err := repo.GetAddressByID(ctx, survivor.ID)
+	if err != nil || got.Name != "Frank" {
+		t.Fatalf("survivor after undo = %+v, %v, want Frank", got, err)
+	}
+
+	_, err = repo.GetAddressByID(ctx, duplicate.ID)
+	if err != nil {
+		t.Fatalf("GetAddressByID of the merged address after undo: %s", err)
+	}
+
+	versions, err := repo.GetAddressVersions(ctx, duplicate.ID)
+	if err != nil {
+		t.Fatalf("GetAddressVersions: %s", err)
+	}
+
+	if len(versions) != 3 || versions[0].Action != phonebook.ActionCreate || versions[2].Action != phonebook.ActionRestore {
+		t.Fatalf("merged address history = %v, want create, delete, restore", versions)
+	}
+
+	err = svc.UndoMerge(ctx, userID, merge.ID)
+	if err == nil {
+		t.Fatal("second UndoMerge succeeded")
+	}
+}
+
+func testUndoMergeAfterEdit(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+	svc := phonebook.NewDuplicateService(repo)
+
+	survivor := newAddress(t, repo, ctx, userID, "Grace")
+	duplicate := newAddress(t, repo, ctx, userID, "Grace H")
+
+	merge, err := svc.Merge(ctx, userID, survivor.ID, []int{duplicate.ID}, nil)
+	if err != nil {
+		t.Fatalf("Merge: %s", err)
+	}
+
+	err = repo.UpdateAddress(ctx, survivor.ID, &phonebook.Address{Name: "Grace Hopper", PhoneNumber: survivor.PhoneNumber})
+	if err != nil {
+		t.Fatalf("UpdateAddress: %s", err)
+	}
+
+	err = svc.UndoMerge(ctx, userID, merge.ID)
+	var ce common.ConflictError
+	if !errors.As(err, &ce) {
+		t.Fatalf("UndoMerge after an edit = %v, want a ConflictError", err)
+	}
+
+	got, err := repo.GetAddressByID(ctx, survivor.ID)
+	if err != nil || got.Name != "Grace Hopper" {
+		t.Fatalf("survivor after refused undo = %+v, %v, want the edit kept", got, err)
+	}
+}
+
+func testConcurrentCreates(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+
+	const n = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.NewAddress(ctx, &phonebook.Address{User: &phonebook.User{ID: userID}, Name: fmt.Sprintf("Frank %d", i), PhoneNumber: "1"})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("NewAddress: %s", err)
+		}
+	}
+
+	addresses, err := repo.GetAddressesByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetAddressesByUserID: %s", err)
+	}
+
+	if len(addresses) != n {
+		t.Fatalf("got %d addresses, want %d", len(addresses), n)
+	}
+}
+
+// testConcurrentUpdates races writers holding the same version. Exactly one
+// may win; the others must see a precondition failure, not a lost update.
+func testConcurrentUpdates(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+
+	address := newAddress(t, repo, ctx, userID, "Grace")
+
+	const n = 5
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.UpdateAddress(ctx, address.ID, &phonebook.Address{
+				Name:        fmt.Sprintf("Grace %d", i),
+				PhoneNumber: address.PhoneNumber,
+				Version:     address.Version,
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	won := 0
+	for err := range errs {
+		var pe common.PreconditionFailedError
+		switch {
+		case err == nil:
+			won++
+		case errors.As(err, &pe):
+		default:
+			t.Fatalf("UpdateAddress: %s", err)
+		}
+	}
+
+	if won != 1 {
+		t.Fatalf("%d concurrent updates of version %d succeeded, want 1", won, address.Version)
+	}
+}
+
+func testTransaction(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+
+	rollback := errors.New("rollback")
+	err := repo.WithinTx(ctx, func(ctx context.Context) error {
+		newAddress(t, repo, ctx, userID, "Heidi")
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("WithinTx = %v, want the error of fn", err)
+	}
+
+	addresses, err := repo.GetAddressesByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetAddressesByUserID: %s", err)
+	}
+
+	if len(addresses) != 0 {
+		t.Fatalf("rolled back transaction left %v", addresses)
+	}
+}
+
+func testCancellation(t *testing.T, repo Repository) {
+	ctx, userID := tenant(t, repo, "alice@example.com")
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err := repo.GetAddressesByUserID(ctx, userID)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetAddressesByUserID with a canceled context = %v, want context.Canceled", err)
+	}
+
+	err = repo.NewAddress(ctx, &phonebook.Address{User: &phonebook.User{ID: userID}, Name: "Ivan", PhoneNumber: "1"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("NewAddress with a canceled context = %v, want context.Canceled", err)
+	}
+}
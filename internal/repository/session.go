@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"time"
+)
+
+const sessionColumns = `id, user_id, device_name, ip, user_agent, created_at, last_seen_at, expires_at, revoked_at`
+
+func scanSession(row interface{ Scan(...any) error }) (*phonebook.Session, error) {
+	var session phonebook.Session
+
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceName,
+		&session.IP,
+		&session.UserAgent,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *store) NewSession(ctx context.Context, session *phonebook.Session) error {
+	now := time.Now().UTC()
+	session.CreatedAt = now
+	session.LastSeenAt = now
+
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO sessions (id, user_id, device_name, ip, user_agent, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		session.ID,
+		session.UserID,
+		session.DeviceName,
+		session.IP,
+		session.UserAgent,
+		session.CreatedAt,
+		session.LastSeenAt,
+		session.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (r *store) ExtendSession(ctx context.Context, ID string, expiresAt time.Time) error {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE sessions SET expires_at = $1 WHERE id = $2 AND revoked_at IS NULL`,
+		expiresAt.UTC(),
+		ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "session not found")
+}
+
+func (r *store) GetSession(ctx context.Context, ID string) (*phonebook.Session, error) {
+	session, err := scanSession(r.conn(ctx).QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, ID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "session not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (r *store) GetSessionsByUserID(ctx context.Context, userID int) ([]*phonebook.Session, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_seen_at DESC`,
+		userID,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*phonebook.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *store) TouchSession(ctx context.Context, ID string, lastSeenAt time.Time) error {
+	_, err := r.conn(ctx).ExecContext(ctx, `UPDATE sessions SET last_seen_at = $1 WHERE id = $2`, lastSeenAt.UTC(), ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *store) RevokeSession(ctx context.Context, userID int, ID string) error {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`,
+		time.Now().UTC(),
+		ID,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "session not found")
+}
+
+func (r *store) RevokeOtherSessions(ctx context.Context, userID int, keepID string) (int, error) {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND id <> $3 AND revoked_at IS NULL`,
+		time.Now().UTC(),
+		userID,
+		keepID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+func (r *store) PurgeSessions(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`DELETE FROM sessions WHERE expires_at < $1 OR revoked_at < $1`,
+		before.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
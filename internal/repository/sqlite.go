@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"template/internal/common"
+	"template/internal/phonebook"
+)
+
+// SQLiteRepository stores the phonebook in a single SQLite file for
+// deployments without Postgres. Its transactions take the write lock when
+// they begin, so reads inside one see no concurrent writes; search falls
+// back to phonebook.MatchAddresses.
+type SQLiteRepository struct {
+	store
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{store{db}}
+}
+
+func (r *SQLiteRepository) scoped(ctx context.Context, fn func(q querier, tenantID int) error) error {
+	tenantID, ok := common.TenantID(ctx)
+	if !ok {
+		return errMissingTenant
+	}
+
+	return fn(r.conn(ctx), tenantID)
+}
+
+func (r *SQLiteRepository) scopedTx(ctx context.Context, fn func(tx *sql.Tx, tenantID int) error) error {
+	tenantID, ok := common.TenantID(ctx)
+	if !ok {
+		return errMissingTenant
+	}
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		return fn(tx, tenantID)
+	})
+}
+
+// placeholders returns n numbered placeholders starting at $start, for the
+// IN lists that stand in for Postgres arrays.
+func placeholders(start int, n int) string {
+	res := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		res = append(res, fmt.Sprintf("$%d", start+i))
+	}
+
+	return strings.Join(res, ", ")
+}
+
+func (r *SQLiteRepository) NewAddress(ctx context.Context, address *phonebook.Address) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		return insertAddress(ctx, tx, tenantID, address)
+	})
+}
+
+func (r *SQLiteRepository) NewAddresses(ctx context.Context, addresses []*phonebook.Address) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		for _, address := range addresses {
+			err := insertAddress(ctx, tx, tenantID, address)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *SQLiteRepository) Addresses(ctx context.Context) ([]*phonebook.Address, error) {
+	var res []*phonebook.Address
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		var err error
+		res, err = queryAddresses(ctx, q, tenantAddressesQuery, tenantID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *SQLiteRepository) GetAddressesByUserID(ctx context.Context, userID int) ([]*phonebook.Address, error) {
+	var res []*phonebook.Address
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		var err error
+		res, err = queryAddresses(
+			ctx,
+			q,
+			userAddressesQuery,
+			tenantID,
+			userID,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *SQLiteRepository) GetVisibleAddresses(ctx context.Context, userID int) ([]*phonebook.Address, error) {
+	var res []*phonebook.Address
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		var err error
+		res, err = queryAddresses(
+			ctx,
+			q,
+			visibleAddressesQuery,
+			tenantID,
+			userID,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *SQLiteRepository) GetAddressByID(ctx context.Context, ID int) (*phonebook.Address, error) {
+	var address *phonebook.Address
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		var err error
+		address, err = sqliteGetAddress(ctx, q, tenantID, ID, false)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+// LockAddress reads the address like GetAddressByID. SQLite transactions
+// already hold the write lock, so there is no row lock to take.
+func (r *SQLiteRepository) LockAddress(ctx context.Context, ID int, deleted bool) (*phonebook.Address, error) {
+	var address *phonebook.Address
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		var err error
+		address, err = sqliteGetAddress(ctx, q, tenantID, ID, deleted)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+func sqliteGetAddress(ctx context.Context, q querier, tenantID int, ID int, deleted bool) (*phonebook.Address, error) {
+	address := phonebook.Address{User: &phonebook.User{}}
+	var collectionID sql.NullInt64
+
+	err := q.QueryRowContext(
+		ctx,
+		`SELECT id, user_id, collection_id, name, phone_number, version, deleted_at FROM addresses
+		WHERE organization_id = $1 AND id = $2 AND (deleted_at IS NOT NULL) = $3`,
+		tenantID,
+		ID,
+		deleted,
+	).Scan(&address.ID, &address.User.ID, &collectionID, &address.Name, &address.PhoneNumber, &address.Version, &address.DeletedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, addressNotFound(deleted)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if collectionID.Valid {
+		address.Collection = &phonebook.Collection{ID: int(collectionID.Int64)}
+	}
+
+	return &address, nil
+}
+
+func (r *SQLiteRepository) SearchAddresses(ctx context.Context, userID int, query string, limit int) ([]*phonebook.Address, error) {
+	addresses, err := r.GetAddressesByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return phonebook.MatchAddresses(addresses, query, limit), nil
+}
+
+func (r *SQLiteRepository) UpdateAddress(ctx context.Context, ID int, address *phonebook.Address) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		return sqliteUpdateAddress(ctx, tx, tenantID, ID, address, phonebook.ActionUpdate)
+	})
+}
+
+func (r *SQLiteRepository) DeleteAddress(ctx context.Context, ID int, version int) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		address, err := sqliteGetAddress(ctx, tx, tenantID, ID, false)
+		if err != nil {
+			return err
+		}
+
+		err = checkVersion(address, version)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `UPDATE addresses SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1`, ID)
+		if err != nil {
+			return err
+		}
+
+		err = checkAffected(res, "address not found")
+		if err != nil {
+			return err
+		}
+
+		return recordVersion(ctx, tx, phonebook.ActionDelete, address, address)
+	})
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"template/internal/phonebook"
+)
+
+func (r *SQLiteRepository) GetAddressesByCollectionID(ctx context.Context, collectionID int) ([]*phonebook.Address, error) {
+	var res []*phonebook.Address
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		var err error
+		res, err = queryCollectionAddresses(ctx, q, tenantID, collectionID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *SQLiteRepository) SetAddressCollection(ctx context.Context, addressID int, collectionID *int) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		return setAddressCollection(ctx, tx, tenantID, addressID, collectionID)
+	})
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"template/internal/phonebook"
+)
+
+func (r *SQLiteRepository) RevertAddress(ctx context.Context, ID int, to *phonebook.AddressVersion) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		return sqliteUpdateAddress(ctx, tx, tenantID, ID, &phonebook.Address{Name: to.Name, PhoneNumber: to.PhoneNumber}, phonebook.ActionRevert)
+	})
+}
+
+// sqliteUpdateAddress is updateAddress without the row lock, which the
+// transaction's write lock makes unnecessary.
+func sqliteUpdateAddress(ctx context.Context, tx *sql.Tx, tenantID int, ID int, address *phonebook.Address, action phonebook.Action) error {
+	before, err := sqliteGetAddress(ctx, tx, tenantID, ID, false)
+	if err != nil {
+		return err
+	}
+
+	err = checkVersion(before, address.Version)
+	if err != nil {
+		return err
+	}
+
+	after := &phonebook.Address{ID: ID, User: before.User, Name: address.Name, PhoneNumber: address.PhoneNumber}
+
+	changes := phonebook.DiffAddresses(before, after)
+	if len(changes) == 0 {
+		address.Version = before.Version
+		return nil
+	}
+
+	sets := make([]string, 0, len(changes))
+	args := make([]any, 0, len(changes)+1)
+	for _, column := range []string{phonebook.ColumnName, phonebook.ColumnPhoneNumber} {
+		if change, ok := changes[column]; ok {
+			args = append(args, change.To)
+			sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+		}
+	}
+	args = append(args, ID)
+
+	err = tx.QueryRowContext(
+		ctx,
+		fmt.Sprintf(`UPDATE addresses SET %s, version = version + 1 WHERE id = $%d RETURNING version`, strings.Join(sets, ", "), len(args)),
+		args...,
+	).Scan(&address.Version)
+	if err != nil {
+		return err
+	}
+
+	return recordVersion(ctx, tx, action, before, after)
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"template/internal/common"
+	"time"
+)
+
+func (r *SQLiteRepository) ReserveIdempotencyKey(ctx context.Context, record *common.IdempotencyRecord) (*common.IdempotencyRecord, error) {
+	var reserved bool
+
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO idempotency_keys (user_id, key, request_hash, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, key) DO UPDATE
+			SET request_hash = EXCLUDED.request_hash, expires_at = EXCLUDED.expires_at,
+				status_code = NULL, header = NULL, body = NULL
+			WHERE idempotency_keys.expires_at < $5
+		RETURNING true`,
+		record.UserID,
+		record.Key,
+		record.RequestHash,
+		record.ExpiresAt.UTC(),
+		time.Now().UTC(),
+	).Scan(&reserved)
+
+	if err == nil {
+		return nil, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	existing := common.IdempotencyRecord{UserID: record.UserID, Key: record.Key}
+	var statusCode sql.NullInt64
+	var header []byte
+
+	err = r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT request_hash, status_code, header, body, expires_at FROM idempotency_keys WHERE user_id = $1 AND key = $2`,
+		record.UserID,
+		record.Key,
+	).Scan(&existing.RequestHash, &statusCode, &header, &existing.Body, &existing.ExpiresAt)
+
+	// The key was released between the insert and this read; reserve again.
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.ReserveIdempotencyKey(ctx, record)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing.StatusCode = int(statusCode.Int64)
+	if header != nil {
+		err = json.Unmarshal(header, &existing.Header)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &existing, nil
+}
+
+func (r *SQLiteRepository) CompleteIdempotencyKey(ctx context.Context, record *common.IdempotencyRecord) error {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE idempotency_keys SET status_code = $1, header = $2, body = $3, expires_at = $4 WHERE user_id = $5 AND key = $6`,
+		record.StatusCode,
+		header,
+		record.Body,
+		record.ExpiresAt.UTC(),
+		record.UserID,
+		record.Key,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) ReleaseIdempotencyKey(ctx context.Context, userID int, key string) error {
+	_, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`, userID, key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+)
+
+func (r *SQLiteRepository) GetMergeByID(ctx context.Context, ID int) (*phonebook.Merge, error) {
+	var merge *phonebook.Merge
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		var err error
+		merge, err = queryMerge(ctx, q, tenantID, ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+func (r *SQLiteRepository) MergeAddresses(ctx context.Context, merge *phonebook.Merge) error {
+	previous, err := json.Marshal(newAddressSnapshot(merge.Previous))
+	if err != nil {
+		return err
+	}
+
+	snapshots := make([]addressSnapshot, 0, len(merge.Merged))
+	ids := make([]any, 0, len(merge.Merged))
+	for _, address := range merge.Merged {
+		snapshots = append(snapshots, newAddressSnapshot(address))
+		ids = append(ids, address.ID)
+	}
+
+	merged, err := json.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		current, err := sqliteGetAddress(ctx, tx, tenantID, merge.Survivor.ID, false)
+		if err != nil {
+			return err
+		}
+
+		err = checkVersion(current, merge.Previous.Version)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(
+			ctx,
+			`UPDATE addresses SET name = $1, phone_number = $2, version = version + 1
+			WHERE organization_id = $3 AND id = $4 AND deleted_at IS NULL
+			RETURNING version`,
+			merge.Survivor.Name,
+			merge.Survivor.PhoneNumber,
+			tenantID,
+			merge.Survivor.ID,
+		).Scan(&merge.Survivor.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return common.NotFoundError{Message: "address not found"}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = recordVersion(ctx, tx, phonebook.ActionMerge, merge.Previous, merge.Survivor)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO address_tags (address_id, tag_id)
+			SELECT $1, tag_id FROM address_tags WHERE address_id IN (`+placeholders(2, len(ids))+`)
+			ON CONFLICT DO NOTHING`,
+			append([]any{merge.Survivor.ID}, ids...)...,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, address := range merge.Merged {
+			current, err := sqliteGetAddress(ctx, tx, tenantID, address.ID, false)
+			if err != nil {
+				return err
+			}
+
+			err = checkVersion(current, address.Version)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE addresses SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1`, address.ID)
+			if err != nil {
+				return err
+			}
+
+			err = recordVersion(ctx, tx, phonebook.ActionDelete, current, current)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.QueryRowContext(
+			ctx,
+			`INSERT INTO address_merges (organization_id, user_id, survivor_id, survivor_version, previous, merged) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+			tenantID,
+			merge.User.ID,
+			merge.Survivor.ID,
+			merge.Survivor.Version,
+			previous,
+			merged,
+		).Scan(&merge.ID, &merge.CreatedAt)
+	})
+}
+
+func (r *SQLiteRepository) UndoMerge(ctx context.Context, merge *phonebook.Merge) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		current, err := sqliteGetAddress(ctx, tx, tenantID, merge.Survivor.ID, false)
+		if err != nil {
+			return err
+		}
+
+		if current.Version != merge.Survivor.Version {
+			return common.ConflictError{Message: "address was changed after the merge, undo is no longer possible"}
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE addresses SET name = $1, phone_number = $2, version = version + 1 WHERE id = $3`,
+			merge.Previous.Name,
+			merge.Previous.PhoneNumber,
+			merge.Survivor.ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = recordVersion(ctx, tx, phonebook.ActionRevert, current, merge.Previous)
+		if err != nil {
+			return err
+		}
+
+		for _, address := range merge.Merged {
+			merged, err := sqliteGetAddress(ctx, tx, tenantID, address.ID, true)
+			if errors.As(err, &common.NotFoundError{}) {
+				return common.ConflictError{Message: "a merged address is no longer in the trash, undo is no longer possible"}
+			}
+
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE addresses SET deleted_at = NULL, version = version + 1 WHERE id = $1`, address.ID)
+			if err != nil {
+				return err
+			}
+
+			err = recordVersion(ctx, tx, phonebook.ActionRestore, merged, merged)
+			if err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, `UPDATE address_merges SET undone_at = CURRENT_TIMESTAMP WHERE organization_id = $1 AND id = $2 AND undone_at IS NULL`, tenantID, merge.ID)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return common.InvariantError{Message: "merge already undone"}
+		}
+
+		return nil
+	})
+}
+
+func (r *SQLiteRepository) GetDuplicatePairs(ctx context.Context, userID int, limit int) ([]phonebook.DuplicatePair, error) {
+	addresses, err := r.GetAddressesByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return phonebook.FindDuplicatePairs(addresses, limit), nil
+}
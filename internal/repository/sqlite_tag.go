@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"template/internal/phonebook"
+)
+
+func (r *SQLiteRepository) TagAddresses(ctx context.Context, tagIDs []int, addressIDs []int) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		for _, addressID := range addressIDs {
+			for _, tagID := range tagIDs {
+				_, err := tx.ExecContext(
+					ctx,
+					`INSERT INTO address_tags (address_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+					addressID,
+					tagID,
+				)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *SQLiteRepository) UntagAddresses(ctx context.Context, tagIDs []int, addressIDs []int) error {
+	args := make([]any, 0, len(addressIDs)+len(tagIDs))
+	for _, id := range addressIDs {
+		args = append(args, id)
+	}
+	for _, id := range tagIDs {
+		args = append(args, id)
+	}
+
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`DELETE FROM address_tags
+		WHERE address_id IN (`+placeholders(1, len(addressIDs))+`)
+		AND tag_id IN (`+placeholders(len(addressIDs)+1, len(tagIDs))+`)`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) GetAddressesByTag(ctx context.Context, userID int, tag string) ([]*phonebook.Address, error) {
+	var res []*phonebook.Address
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		var err error
+		res, err = queryAddresses(
+			ctx,
+			q,
+			`SELECT a.id, a.user_id, a.name, a.phone_number
+			FROM addresses a
+			JOIN address_tags at ON at.address_id = a.id
+			JOIN tags t ON t.id = at.tag_id
+			WHERE a.organization_id = $1 AND a.deleted_at IS NULL AND t.user_id = $2 AND t.name = $3`,
+			tenantID,
+			userID,
+			tag,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
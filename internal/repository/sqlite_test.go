@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"path/filepath"
+	"template/internal/db"
+	"template/internal/repository/repotest"
+	"testing"
+)
+
+func newSQLiteRepository(t testing.TB) *SQLiteRepository {
+	t.Helper()
+
+	conn, err := db.ConnectSQLite(filepath.Join(t.TempDir(), "phonebook.db"))
+	if err != nil {
+		t.Fatalf("ConnectSQLite: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return NewSQLiteRepository(conn)
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return newSQLiteRepository(t)
+	})
+}
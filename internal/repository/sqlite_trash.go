@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"template/internal/phonebook"
+	"time"
+)
+
+func (r *SQLiteRepository) GetDeletedAddressesByUserID(ctx context.Context, userID int) ([]*phonebook.Address, error) {
+	res := make([]*phonebook.Address, 0)
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		rows, err := q.QueryContext(
+			ctx,
+			`SELECT id, user_id, name, phone_number, deleted_at FROM addresses
+			WHERE organization_id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+			ORDER BY deleted_at DESC`,
+			tenantID,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			cur := phonebook.Address{User: &phonebook.User{}}
+			err = rows.Scan(
+				&cur.ID,
+				&cur.User.ID,
+				&cur.Name,
+				&cur.PhoneNumber,
+				&cur.DeletedAt,
+			)
+			if err != nil {
+				return err
+			}
+
+			res = append(res, &cur)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *SQLiteRepository) GetDeletedAddressByID(ctx context.Context, ID int) (*phonebook.Address, error) {
+	var address *phonebook.Address
+
+	err := r.scoped(ctx, func(q querier, tenantID int) error {
+		var err error
+		address, err = sqliteGetAddress(ctx, q, tenantID, ID, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+func (r *SQLiteRepository) RestoreAddress(ctx context.Context, ID int) error {
+	return r.scopedTx(ctx, func(tx *sql.Tx, tenantID int) error {
+		address, err := sqliteGetAddress(ctx, tx, tenantID, ID, true)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `UPDATE addresses SET deleted_at = NULL, version = version + 1 WHERE id = $1`, ID)
+		if err != nil {
+			return err
+		}
+
+		err = checkAffected(res, "address not found in trash")
+		if err != nil {
+			return err
+		}
+
+		return recordVersion(ctx, tx, phonebook.ActionRestore, address, address)
+	})
+}
+
+func (r *SQLiteRepository) PurgeDeletedAddresses(ctx context.Context, before time.Time) (int, error) {
+	res, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM addresses WHERE deleted_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+)
+
+func (r *store) NewTag(ctx context.Context, tag *phonebook.Tag) error {
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO tags (user_id, name) VALUES ($1, $2) RETURNING id`,
+		tag.User.ID,
+		tag.Name,
+	).Scan(&tag.ID)
+
+	if err != nil {
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (r *store) GetTagsByUserID(ctx context.Context, userID int) ([]*phonebook.Tag, error) {
+	res := make([]*phonebook.Tag, 0)
+
+	rows, err := r.conn(ctx).QueryContext(ctx, `SELECT id, user_id, name FROM tags WHERE user_id = $1 ORDER BY name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cur := phonebook.Tag{User: &phonebook.User{}}
+		err = rows.Scan(&cur.ID, &cur.User.ID, &cur.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &cur)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetTagsByAddressID lists the tags on an address, of whoever put them there.
+func (r *store) GetTagsByAddressID(ctx context.Context, addressID int) ([]*phonebook.Tag, error) {
+	res := make([]*phonebook.Tag, 0)
+
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT t.id, t.user_id, t.name FROM tags t JOIN address_tags at ON at.tag_id = t.id WHERE at.address_id = $1 ORDER BY t.name`,
+		addressID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cur := phonebook.Tag{User: &phonebook.User{}}
+		err = rows.Scan(&cur.ID, &cur.User.ID, &cur.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &cur)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *store) GetTagByID(ctx context.Context, ID int) (*phonebook.Tag, error) {
+	tag := phonebook.Tag{User: &phonebook.User{}}
+
+	err := r.conn(ctx).QueryRowContext(ctx, `SELECT id, user_id, name FROM tags WHERE id = $1`, ID).
+		Scan(&tag.ID, &tag.User.ID, &tag.Name)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "tag not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+func (r *store) UpdateTag(ctx context.Context, ID int, tag *phonebook.Tag) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `UPDATE tags SET name = $1 WHERE id = $2`, tag.Name, ID)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return checkAffected(res, "tag not found")
+}
+
+func (r *store) DeleteTag(ctx context.Context, ID int) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM tags WHERE id = $1`, ID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "tag not found")
+}
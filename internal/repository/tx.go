@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+type txKey struct{}
+
+// store holds the database shared by every backend, along with the queries
+// written in SQL that both Postgres and SQLite accept.
+type store struct {
+	db *sql.DB
+}
+
+func (r *store) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.db, fn)
+}
+
+func (r *store) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return inTx(ctx, r.db, fn)
+}
+
+func (r *store) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+// withinTx runs fn with a context carrying a transaction on db, joining the
+// one ctx already carries.
+func withinTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	return inTx(ctx, db, func(tx *sql.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// inTx runs fn in the transaction carried by ctx. Without one it begins a
+// transaction on db that commits when fn succeeds. Errors pass through
+// dbError.
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return dbError(fn(tx))
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError(err)
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return dbError(tx.Commit())
+}
+
+// conn returns the transaction carried by ctx, or db outside of one.
+func conn(ctx context.Context, db *sql.DB) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+
+	return db
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"template/internal/common"
+	"template/internal/phonebook"
+	"time"
+)
+
+const userColumns = `id, email, password, display_name, locale, timezone, avatar_url`
+
+func scanUser(row interface{ Scan(...any) error }) (*phonebook.User, error) {
+	var user phonebook.User
+
+	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.DisplayName, &user.Locale, &user.Timezone, &user.AvatarURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "user not found"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *store) NewUser(ctx context.Context, user *phonebook.User) (int, error) {
+	var id int
+
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO users (email, password, display_name) VALUES ($1, $2, $3) RETURNING ID`,
+		user.Email,
+		user.Password,
+		user.DisplayName,
+	).Scan(&id)
+
+	if err != nil {
+		return -1, dbError(err)
+	}
+
+	return id, nil
+}
+
+func (r *store) GetUserByEmail(ctx context.Context, email string) (*phonebook.User, error) {
+	return scanUser(r.conn(ctx).QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email))
+}
+
+func (r *store) GetUserByIdentity(ctx context.Context, issuer string, subject string) (*phonebook.User, error) {
+	return scanUser(r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT `+userColumns+` FROM users WHERE id = (SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2)`,
+		issuer,
+		subject,
+	))
+}
+
+func (r *store) NewIdentity(ctx context.Context, userID int, identity *phonebook.Identity) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO user_identities (issuer, subject, user_id, email, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		identity.Issuer,
+		identity.Subject,
+		userID,
+		identity.Email,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (r *store) GetIdentitiesByUserID(ctx context.Context, userID int) ([]*phonebook.Identity, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT issuer, subject, email, created_at FROM user_identities WHERE user_id = $1 ORDER BY created_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := make([]*phonebook.Identity, 0)
+	for rows.Next() {
+		var identity phonebook.Identity
+
+		err := rows.Scan(&identity.Issuer, &identity.Subject, &identity.Email, &identity.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, &identity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+func (r *store) GetUserByID(ctx context.Context, ID int) (*phonebook.User, error) {
+	return scanUser(r.conn(ctx).QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, ID))
+}
+
+func (r *store) UpdateProfile(ctx context.Context, user *phonebook.User) error {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE users SET display_name = $1, locale = $2, timezone = $3, avatar_url = $4 WHERE id = $5`,
+		user.DisplayName,
+		user.Locale,
+		user.Timezone,
+		user.AvatarURL,
+		user.ID,
+	)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return checkAffected(res, "user not found")
+}
+
+func (r *store) UpdatePassword(ctx context.Context, userID int, password string) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `UPDATE users SET password = $1 WHERE id = $2`, password, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "user not found")
+}
+
+func (r *store) UpdateEmail(ctx context.Context, userID int, email string) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `UPDATE users SET email = $1 WHERE id = $2`, email, userID)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return checkAffected(res, "user not found")
+}
+
+func (r *store) NewEmailChange(ctx context.Context, change *phonebook.EmailChange) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO email_changes (user_id, email, token_hash, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+			SET email = EXCLUDED.email, token_hash = EXCLUDED.token_hash, expires_at = EXCLUDED.expires_at`,
+		change.UserID,
+		change.Email,
+		change.TokenHash,
+		change.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func (r *store) GetEmailChange(ctx context.Context, userID int) (*phonebook.EmailChange, error) {
+	change := phonebook.EmailChange{UserID: userID}
+
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT email, token_hash, expires_at FROM email_changes WHERE user_id = $1`,
+		userID,
+	).Scan(&change.Email, &change.TokenHash, &change.ExpiresAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, common.NotFoundError{Message: "no pending email change"}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+func (r *store) DeleteEmailChange(ctx context.Context, userID int) error {
+	res, err := r.conn(ctx).ExecContext(ctx, `DELETE FROM email_changes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res, "no pending email change")
+}
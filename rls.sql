@@ -0,0 +1,56 @@
+-- Optional row-level security for tenant isolation. Apply after init.sql,
+-- as a superuser, and set DATABASE_RLS=true so the repository sets
+-- app.tenant_id on every address query. The policy fails closed: a query
+-- that does not set app.tenant_id sees no addresses.
+
+ALTER TABLE Addresses ENABLE ROW LEVEL SECURITY;
+ALTER TABLE Addresses FORCE ROW LEVEL SECURITY;
+
+-- An unset app.tenant_id reads as NULL, and as '' once a transaction that
+-- set it has ended; both match no organization.
+CREATE POLICY addresses_tenant_isolation ON Addresses
+    USING (organization_id = NULLIF(current_setting('app.tenant_id', true), '')::BIGINT)
+    WITH CHECK (organization_id = NULLIF(current_setting('app.tenant_id', true), '')::BIGINT);
+
+-- Deleting a user and purging the trash cross organizations. They run as
+-- phonebook_maintenance through the functions below, which is the only way
+-- the application reaches addresses without a tenant.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'phonebook_maintenance') THEN
+        CREATE ROLE phonebook_maintenance NOLOGIN;
+    END IF;
+END
+$$;
+
+GRANT SELECT, DELETE ON Addresses TO phonebook_maintenance;
+
+CREATE POLICY addresses_maintenance ON Addresses TO phonebook_maintenance
+    USING (true);
+
+CREATE FUNCTION delete_user_addresses(uid BIGINT) RETURNS BIGINT AS $$
+    WITH deleted AS (DELETE FROM Addresses WHERE user_id = uid RETURNING 1)
+    SELECT count(*) FROM deleted;
+$$ LANGUAGE sql SECURITY DEFINER SET search_path FROM CURRENT;
+
+CREATE FUNCTION purge_deleted_addresses(before TIMESTAMPTZ) RETURNS BIGINT AS $$
+    WITH deleted AS (DELETE FROM Addresses WHERE deleted_at < before RETURNING 1)
+    SELECT count(*) FROM deleted;
+$$ LANGUAGE sql SECURITY DEFINER SET search_path FROM CURRENT;
+
+ALTER FUNCTION delete_user_addresses(BIGINT) OWNER TO phonebook_maintenance;
+ALTER FUNCTION purge_deleted_addresses(TIMESTAMPTZ) OWNER TO phonebook_maintenance;
+
+-- Only the application, which owns the tables, may call them.
+REVOKE EXECUTE ON FUNCTION delete_user_addresses(BIGINT) FROM PUBLIC;
+REVOKE EXECUTE ON FUNCTION purge_deleted_addresses(TIMESTAMPTZ) FROM PUBLIC;
+
+DO $$
+DECLARE
+    owner NAME := (SELECT tableowner FROM pg_tables WHERE schemaname = current_schema() AND tablename = 'addresses');
+BEGIN
+    EXECUTE format('GRANT USAGE ON SCHEMA %I TO phonebook_maintenance', current_schema());
+    EXECUTE format('GRANT EXECUTE ON FUNCTION delete_user_addresses(BIGINT) TO %I', owner);
+    EXECUTE format('GRANT EXECUTE ON FUNCTION purge_deleted_addresses(TIMESTAMPTZ) TO %I', owner);
+END
+$$;